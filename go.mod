@@ -1,6 +1,6 @@
 module github.com/Venafi/terraform-provider-venafi
 
-go 1.12
+go 1.13
 
 require (
 	github.com/Venafi/vcert v0.0.0-20190312132320-36e6e55f3d2f
@@ -28,6 +28,8 @@ require (
 	github.com/posener/complete v1.2.1 // indirect
 	github.com/smartystreets/goconvey v0.0.0-20190330032615-68dc04aab96a // indirect
 	github.com/zclconf/go-cty v0.0.0-20181017232614-01c5aba823a6 // indirect
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
+	golang.org/x/net v0.0.0-20190311183353-d8887717615a
 	google.golang.org/genproto v0.0.0-20181109154231-b5d43981345b // indirect
 	gopkg.in/ini.v1 v1.39.0 // indirect
 )
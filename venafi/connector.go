@@ -0,0 +1,159 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"strconv"
+)
+
+const (
+	connectorTPP   = "tpp"
+	connectorCloud = "cloud"
+	connectorDev   = "dev"
+
+	//settingComputed stands for values which are only known after apply, they count as set during validation
+	settingComputed = "(computed)"
+)
+
+func validateConnector(v interface{}, k string) (ws []string, errs []error) {
+	switch v.(string) {
+	case connectorTPP, connectorCloud, connectorDev:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of %s, %s or %s, got %s", k, connectorTPP, connectorCloud, connectorDev, v))
+	}
+	return
+}
+
+// connectorSettings are the provider attributes which choose and authenticate the connector
+type connectorSettings struct {
+	connector   string
+	url         string
+	tppUser     string
+	tppPassword string
+	apiKey      string
+	devMode     bool
+}
+
+// resolve returns the connector to use. Without connector it's guessed from the credentials like before connector
+// was added. Missing and conflicting attributes of the connector are reported with the attribute to fix.
+func (s connectorSettings) resolve() (string, error) {
+	connector := s.connector
+	switch {
+	case connector == "" && s.devMode:
+		connector = connectorDev
+	case connector == "" && (s.tppUser != "" || s.tppPassword != ""):
+		if s.tppUser == "" || s.tppPassword == "" {
+			return "", credentialsError(s.url, s.tppUser, s.tppPassword)
+		}
+		connector = connectorTPP
+	case connector == "" && s.apiKey != "":
+		connector = connectorCloud
+	case connector == "":
+		return "", credentialsError(s.url, s.tppUser, s.tppPassword)
+	case s.devMode && connector != connectorDev:
+		return "", &diagnostic{
+			summary:   fmt.Sprintf("dev_mode = true conflicts with connector = %q", connector),
+			attribute: "dev_mode",
+			hint:      `remove dev_mode or use connector = "dev"`,
+		}
+	}
+
+	var required [][2]string
+	conflicting := ""
+	switch connector {
+	case connectorTPP:
+		required = [][2]string{{"url", s.url}, {"tpp_username", s.tppUser}, {"tpp_password", s.tppPassword}}
+		if s.apiKey != "" {
+			conflicting = "api_key"
+		}
+	case connectorCloud:
+		required = [][2]string{{"api_key", s.apiKey}}
+		if s.tppUser != "" {
+			conflicting = "tpp_username"
+		} else if s.tppPassword != "" {
+			conflicting = "tpp_password"
+		}
+	}
+	for _, attribute := range required {
+		if attribute[1] == "" {
+			return "", &diagnostic{
+				summary:   fmt.Sprintf("%s is required for %s", attribute[0], connectorNames[connector]),
+				attribute: attribute[0],
+				backend:   connectorNames[connector],
+				hint:      fmt.Sprintf("set %s or the %s environment variable", attribute[0], providerEnvVars[attribute[0]]),
+			}
+		}
+	}
+	if conflicting != "" {
+		return "", &diagnostic{
+			summary:   fmt.Sprintf("%s can't be used with connector = %q", conflicting, connector),
+			attribute: conflicting,
+			backend:   connectorNames[connector],
+			hint:      fmt.Sprintf("remove %s or choose the connector it belongs to", conflicting),
+		}
+	}
+	return connector, nil
+}
+
+var (
+	connectorNames = map[string]string{
+		connectorTPP:   "Venafi Platform",
+		connectorCloud: "Venafi Cloud",
+		connectorDev:   "dev mode",
+	}
+	providerEnvVars = map[string]string{
+		"url":          "VENAFI_URL",
+		"tpp_username": "VENAFI_USER",
+		"tpp_password": "VENAFI_PASS",
+		"api_key":      "VENAFI_API",
+	}
+)
+
+// venafiProvider validates the connector settings together, which schema.Provider can only do attribute by
+// attribute, so misconfigurations fail before the provider is configured
+type venafiProvider struct {
+	*schema.Provider
+}
+
+func (p *venafiProvider) Validate(c *terraform.ResourceConfig) ([]string, []error) {
+	ws, errs := p.Provider.Validate(c)
+	if len(errs) > 0 {
+		return ws, errs
+	}
+	settings := connectorSettings{
+		connector:   p.setting(c, "connector"),
+		url:         p.setting(c, "url"),
+		tppUser:     p.setting(c, "tpp_username"),
+		tppPassword: p.setting(c, "tpp_password"),
+		apiKey:      p.setting(c, "api_key"),
+	}
+	//dev_mode can't be checked until it's known
+	devMode := p.setting(c, "dev_mode")
+	if devMode == settingComputed {
+		return ws, errs
+	}
+	settings.devMode, _ = strconv.ParseBool(devMode)
+	if settings.connector == settingComputed {
+		return ws, errs
+	}
+	if _, err := settings.resolve(); err != nil {
+		errs = append(errs, err)
+	}
+	return ws, errs
+}
+
+// setting returns the configured value of a provider attribute or its environment variable default
+func (p *venafiProvider) setting(c *terraform.ResourceConfig, key string) string {
+	if c.IsComputed(key) {
+		return settingComputed
+	}
+	if v, ok := c.Get(key); ok {
+		return fmt.Sprint(v)
+	}
+	v, err := p.Schema[key].DefaultValue()
+	if err != nil || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
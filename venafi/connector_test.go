@@ -0,0 +1,71 @@
+package venafi
+
+import (
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"regexp"
+	"testing"
+)
+
+func TestConnectorSettings(t *testing.T) {
+	cases := []struct {
+		settings  connectorSettings
+		connector string
+		err       string
+	}{
+		{connectorSettings{devMode: true, apiKey: "key"}, connectorDev, ""},
+		{connectorSettings{url: "https://tpp", tppUser: "admin", tppPassword: "secret"}, connectorTPP, ""},
+		{connectorSettings{apiKey: "key"}, connectorCloud, ""},
+		{connectorSettings{connector: connectorCloud, url: "https://cloud/", apiKey: "key"}, connectorCloud, ""},
+		{connectorSettings{connector: connectorDev}, connectorDev, ""},
+		{connectorSettings{url: "https://tpp", tppUser: "admin", tppPassword: "secret", apiKey: "key"}, "", "api_key can't be used with connector = \"tpp\""},
+		{connectorSettings{connector: connectorCloud, tppPassword: "secret", apiKey: "key"}, "", "tpp_password can't be used with connector = \"cloud\""},
+		{connectorSettings{connector: connectorTPP, url: "https://tpp", tppUser: "admin"}, "", "tpp_password is required for Venafi Platform"},
+		{connectorSettings{connector: connectorCloud}, "", "api_key is required for Venafi Cloud"},
+		{connectorSettings{connector: connectorTPP, devMode: true}, "", "dev_mode = true conflicts with connector = \"tpp\""},
+		{connectorSettings{}, "", "no Venafi credentials configured"},
+	}
+	for _, c := range cases {
+		connector, err := c.settings.resolve()
+		if c.err == "" && err != nil {
+			t.Errorf("%+v: unexpected error %s", c.settings, err)
+		} else if c.err != "" && (err == nil || !regexp.MustCompile(regexp.QuoteMeta(c.err)).MatchString(err.Error())) {
+			t.Errorf("%+v: expected error %q, got %v", c.settings, c.err, err)
+		}
+		if connector != c.connector {
+			t.Errorf("%+v: expected connector %q, got %q", c.settings, c.connector, connector)
+		}
+	}
+}
+
+func TestProviderConnectorValidation(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config:      fmt.Sprintf(credentialsConfig, `connector = "venafi"`),
+				ExpectError: regexp.MustCompile(`connector must be one of tpp, cloud or dev, got venafi`),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(credentialsConfig, `connector = "cloud"`+"\n"+`zone = "Default"`),
+				ExpectError: regexp.MustCompile(`(?s)api_key is required for Venafi Cloud.*attribute: api_key.*suggestion: set api_key or the VENAFI_API environment variable`),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(credentialsConfig, `connector = "tpp"`+"\n"+`tpp_username = "admin"`+"\n"+`tpp_password = "secret"`),
+				ExpectError: regexp.MustCompile(`(?s)url is required for Venafi Platform.*attribute: url`),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(credentialsConfig, `connector = "tpp"`+"\n"+`url = "https://tpp.venafi.example/vedsdk"`+"\n"+`tpp_username = "admin"`+"\n"+`tpp_password = "secret"`+"\n"+`api_key = "key"`),
+				ExpectError: regexp.MustCompile(`conflicts with|can't be used with connector`),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(credentialsConfig, `connector = "tpp"`+"\n"+`dev_mode = true`),
+				ExpectError: regexp.MustCompile(`(?s)dev_mode = true conflicts with connector = "tpp".*attribute: dev_mode`),
+			},
+			r.TestStep{
+				Config: fmt.Sprintf(credentialsConfig, `connector = "dev"`),
+				Check:  r.TestCheckResourceAttr("venafi_certificate.test", "common_name", "credentials.venafi.example"),
+			},
+		},
+	})
+}
@@ -0,0 +1,124 @@
+package venafi
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/certificate"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"log"
+	"strings"
+)
+
+func dataSourceVenafiCertificate() *schema.Resource {
+	source := &schema.Resource{
+		Read: dataSourceVenafiCertificateRead,
+
+		Schema: map[string]*schema.Schema{
+			"certificate_dn": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				Description:   "DN of the certificate object in Venafi Platform",
+				ConflictsWith: []string{"pickup_id", "thumbprint"},
+			},
+			"pickup_id": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				Description:   "Pickup ID returned when the certificate was requested",
+				ConflictsWith: []string{"certificate_dn", "thumbprint"},
+			},
+			"thumbprint": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				Description:   "SHA-1 fingerprint of the certificate as hex",
+				ConflictsWith: []string{"certificate_dn", "pickup_id"},
+			},
+			"certificate": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"chain": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+	for k, v := range certificateMetadataSchema() {
+		source.Schema[k] = v
+	}
+	return source
+}
+
+func dataSourceVenafiCertificateRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	req := &certificate.Request{}
+	switch {
+	case d.Get("certificate_dn").(string) != "":
+		if config.vcert.ConnectorType == endpoint.ConnectorTypeCloud {
+			diagnostic := newDiagnostic(config, "certificate_dn lookup is supported only by Venafi Platform", nil)
+			diagnostic.attribute = "certificate_dn"
+			diagnostic.hint = "use pickup_id or thumbprint"
+			return diagnostic
+		}
+		//Venafi Platform uses certificate DN as pickup ID
+		req.PickupID = d.Get("certificate_dn").(string)
+	case d.Get("pickup_id").(string) != "":
+		req.PickupID = d.Get("pickup_id").(string)
+	case d.Get("thumbprint").(string) != "":
+		req.Thumbprint = normalizeThumbprint(d.Get("thumbprint").(string))
+	default:
+		return fmt.Errorf("one of certificate_dn, pickup_id or thumbprint is required")
+	}
+
+	cl, err := config.newConnector()
+	if err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] Retrieving certificate with pickup ID %q and thumbprint %q", req.PickupID, req.Thumbprint)
+	ctx, cancel := config.context(certificateRetrieveTimeout)
+	defer cancel()
+	var pcc *certificate.PEMCollection
+	err = callContext(ctx, func() (err error) {
+		pcc, err = cl.RetrieveCertificate(req)
+		return
+	})
+	if err != nil {
+		diagnostic := newDiagnostic(config, "error retrieving certificate", err)
+		diagnostic.pickupID = req.PickupID
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			diagnostic.summary = fmt.Sprintf("timed out after %s retrieving certificate", certificateRetrieveTimeout)
+		case context.Canceled:
+			diagnostic.summary = "interrupted while retrieving certificate"
+		}
+		return diagnostic
+	}
+
+	block, _ := pem.Decode([]byte(pcc.Certificate))
+	if block == nil {
+		return fmt.Errorf("error decoding retrieved certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing cert: %s", err)
+	}
+	if err = d.Set("certificate", pcc.Certificate); err != nil {
+		return fmt.Errorf("error setting certificate: %s", err)
+	}
+	if err = d.Set("chain", strings.Join(pcc.Chain, "")); err != nil {
+		return fmt.Errorf("error setting chain: %s", err)
+	}
+	err = setCertificateMetadata(d, cert)
+	if err != nil {
+		return err
+	}
+	d.SetId(d.Get("sha1_fingerprint").(string))
+	return nil
+}
+
+// normalizeThumbprint strips separators which tools add to printed fingerprints, e.g. "AB:CD:..."
+func normalizeThumbprint(thumbprint string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", " ", "").Replace(thumbprint))
+}
@@ -0,0 +1,125 @@
+package venafi
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"github.com/Venafi/vcert"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dev_certificate_lookup_config = `
+provider "venafi" {
+  alias = "dev"
+  dev_mode = true
+}
+resource "venafi_certificate" "dev_certificate" {
+  provider = "venafi.dev"
+  common_name = "dev-lookup.venafi.example.com"
+}
+data "venafi_certificate" "dev" {
+  provider = "venafi.dev"
+  pickup_id = "${venafi_certificate.dev_certificate.certificate_dn}"
+}`
+
+func TestDevCertificateLookup(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: dev_certificate_lookup_config,
+				Check: func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["data.venafi_certificate.dev"].Primary.Attributes
+					if attrs["subject_dn"] != "CN=dev-lookup.venafi.example.com" {
+						return fmt.Errorf("unexpected subject %s", attrs["subject_dn"])
+					}
+					if attrs["chain"] == "" {
+						return fmt.Errorf("chain is empty")
+					}
+					return checkCertificateMetadata(s, "data.venafi_certificate.dev")
+				},
+			},
+		},
+	})
+}
+
+func TestDevCertificateLookupByThumbprint(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: `
+provider "venafi" {
+  dev_mode = true
+}
+resource "venafi_certificate" "dev_certificate" {
+  common_name = "dev-thumbprint.venafi.example.com"
+}
+data "venafi_certificate" "dev" {
+  thumbprint = "${venafi_certificate.dev_certificate.sha1_fingerprint}"
+}`,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("data.venafi_certificate.dev", "subject_dn", "CN=dev-thumbprint.venafi.example.com"),
+					r.TestCheckResourceAttrPair("data.venafi_certificate.dev", "certificate", "venafi_certificate.dev_certificate", "certificate"),
+				),
+			},
+			r.TestStep{
+				Config: `
+provider "venafi" {
+  dev_mode = true
+}
+data "venafi_certificate" "dev" {
+  thumbprint = "AB:CD"
+}`,
+				ExpectError: regexp.MustCompile("certificate with thumbprint ABCD not found in dev mode"),
+			},
+		},
+	})
+}
+
+func TestNormalizeThumbprint(t *testing.T) {
+	if tp := normalizeThumbprint("ab:cd ef"); tp != "ABCDEF" {
+		t.Fatalf("unexpected thumbprint %s", tp)
+	}
+}
+
+func TestCertificateLookupInterrupted(t *testing.T) {
+	release := make(chan struct{})
+	server := newTPPTestServer(t, map[string]http.HandlerFunc{
+		"/vedsdk/certificates/retrieve": func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		},
+	})
+	defer server.Close()
+	defer close(release)
+
+	stopContext, stop := context.WithCancel(context.Background())
+	config := &providerConfig{
+		vcert: &vcert.Config{
+			ConnectorType:   endpoint.ConnectorTypeTPP,
+			BaseUrl:         server.URL + "/vedsdk/",
+			Zone:            "devops",
+			Credentials:     &endpoint.Authentication{User: "admin", Password: "secret"},
+			ConnectionTrust: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+		},
+		stopContext: stopContext,
+	}
+	d := dataSourceVenafiCertificate().TestResourceData()
+	d.Set("pickup_id", "\\VED\\Policy\\devops\\blocked.venafi.example")
+	time.AfterFunc(100*time.Millisecond, stop)
+	start := time.Now()
+	err := dataSourceVenafiCertificateRead(d, config)
+	if err == nil || !strings.Contains(err.Error(), "interrupted while retrieving certificate") {
+		t.Fatalf("expected interrupted lookup, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("lookup wasn't given up on right away, it took %s", time.Since(start))
+	}
+}
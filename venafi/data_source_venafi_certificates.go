@@ -0,0 +1,152 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"log"
+	"time"
+)
+
+// certificateSearchMaxLimit keeps a single search within the page sizes both backends accept
+const certificateSearchMaxLimit = 1000
+
+func dataSourceVenafiCertificates() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceVenafiCertificatesRead,
+
+		Schema: map[string]*schema.Schema{
+			"zone": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Policy folder (searched recursively) or Cloud zone. All certificates are searched when empty.",
+			},
+			"common_name": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Common name pattern",
+			},
+			"san_dns": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "DNS subject alternative name pattern",
+			},
+			"issuer": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Issuer of the certificates",
+			},
+			"key_size": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Key size of the certificates",
+			},
+			"expiring_within": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "Only return certificates which expire within this number of hours",
+			},
+			"limit": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      100,
+				Description:  "Maximum number of certificates to return, at most 1000",
+				ValidateFunc: validateSearchLimit,
+			},
+			"offset": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				Description:  "Number of certificates to skip. Venafi Cloud rounds it down to a multiple of limit.",
+				ValidateFunc: validateNotNegative,
+			},
+			"total_count": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of certificates matching the filter on all pages",
+			},
+			"certificates": &schema.Schema{
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id":               &schema.Schema{Type: schema.TypeString, Computed: true},
+						"certificate_dn":   &schema.Schema{Type: schema.TypeString, Computed: true},
+						"common_name":      &schema.Schema{Type: schema.TypeString, Computed: true},
+						"san_dns":          &schema.Schema{Type: schema.TypeList, Computed: true, Elem: &schema.Schema{Type: schema.TypeString}},
+						"issuer":           &schema.Schema{Type: schema.TypeString, Computed: true},
+						"key_size":         &schema.Schema{Type: schema.TypeInt, Computed: true},
+						"serial_number":    &schema.Schema{Type: schema.TypeString, Computed: true},
+						"sha1_fingerprint": &schema.Schema{Type: schema.TypeString, Computed: true},
+						"not_before":       &schema.Schema{Type: schema.TypeString, Computed: true},
+						"not_after":        &schema.Schema{Type: schema.TypeString, Computed: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+func validateSearchLimit(v interface{}, k string) (ws []string, errs []error) {
+	if v.(int) <= 0 || v.(int) > certificateSearchMaxLimit {
+		errs = append(errs, fmt.Errorf("%s must be between 1 and %d, got %d", k, certificateSearchMaxLimit, v))
+	}
+	return
+}
+
+func dataSourceVenafiCertificatesRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	search := certificateSearch{
+		zone:           d.Get("zone").(string),
+		commonName:     d.Get("common_name").(string),
+		san:            d.Get("san_dns").(string),
+		issuer:         d.Get("issuer").(string),
+		keySize:        d.Get("key_size").(int),
+		expiringWithin: time.Duration(d.Get("expiring_within").(int)) * time.Hour,
+		limit:          d.Get("limit").(int),
+		offset:         d.Get("offset").(int),
+	}
+	log.Printf("[DEBUG] Searching certificates with %+v", search)
+
+	c, err := newRestClient(config.stopContext, config)
+	if err != nil {
+		return newDiagnostic(config, "error connecting to Venafi", err)
+	}
+	var (
+		found []foundCertificate
+		total int
+	)
+	switch config.vcert.ConnectorType {
+	case endpoint.ConnectorTypeTPP:
+		found, total, err = searchTPPCertificates(c, search)
+	default:
+		found, total, err = searchCloudCertificates(c, search)
+	}
+	if err != nil {
+		return newDiagnostic(config.withZone(search.zone), "error searching certificates", err)
+	}
+
+	certificates := make([]interface{}, 0, len(found))
+	for _, cert := range found {
+		certificates = append(certificates, map[string]interface{}{
+			"id":               cert.id,
+			"certificate_dn":   cert.certificateDN,
+			"common_name":      cert.commonName,
+			"san_dns":          cert.sanDNS,
+			"issuer":           cert.issuer,
+			"key_size":         cert.keySize,
+			"serial_number":    cert.serialNumber,
+			"sha1_fingerprint": cert.sha1Fingerprint,
+			"not_before":       cert.notBefore,
+			"not_after":        cert.notAfter,
+		})
+	}
+	if err = d.Set("certificates", certificates); err != nil {
+		return fmt.Errorf("error setting certificates: %s", err)
+	}
+	if err = d.Set("total_count", total); err != nil {
+		return fmt.Errorf("error setting total_count: %s", err)
+	}
+	d.SetId(search.id())
+	return nil
+}
@@ -0,0 +1,45 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceVenafiDevCA() *schema.Resource {
+	source := &schema.Resource{
+		Read: dataSourceVenafiDevCARead,
+
+		Schema: map[string]*schema.Schema{
+			"certificate": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "PEM encoded certificate of the CA issuing certificates in dev mode",
+			},
+			"validity_hours": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Validity of certificates issued in dev mode, in hours",
+			},
+		},
+	}
+	for k, v := range certificateMetadataSchema() {
+		source.Schema[k] = v
+	}
+	return source
+}
+
+func dataSourceVenafiDevCARead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	if config.vcert.ConnectorType != endpoint.ConnectorTypeFake {
+		return fmt.Errorf("venafi_dev_ca is available only in dev mode")
+	}
+	ca := config.dev.ca
+	if err := setCertificateMetadata(d, ca.certificate); err != nil {
+		return err
+	}
+	d.Set("certificate", ca.certificatePEM)
+	d.Set("validity_hours", int(ca.validity.Hours()))
+	d.SetId(d.Get("sha1_fingerprint").(string))
+	return nil
+}
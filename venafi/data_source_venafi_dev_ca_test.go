@@ -0,0 +1,126 @@
+package venafi
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/certificate"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// devTestCA returns a PEM encoded ECDSA CA certificate and key
+func devTestCA(t *testing.T, cn string) (string, string) {
+	pk, err := certificate.GenerateECDSAPrivateKey(certificate.EllipticCurveP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &pk.PublicKey, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := encodePrivateKey(pk, "", privateKeyFormatPKCS8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), keyPEM
+}
+
+const devCAConfig = `
+provider "venafi" {
+  dev_mode = true
+  dev_ca_certificate = <<EOF
+%sEOF
+  dev_ca_private_key = <<EOF
+%sEOF
+  dev_validity_hours = 48
+}
+resource "venafi_certificate" "dev" {
+  common_name = "ca.venafi.example"
+  algorithm = "ECDSA"
+  expiration_window = 24
+}
+data "venafi_dev_ca" "ca" {}`
+
+func TestDevCA(t *testing.T) {
+	caPEM, caKeyPEM := devTestCA(t, "Terraform Dev CA")
+	_, otherKeyPEM := devTestCA(t, "Other Dev CA")
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: fmt.Sprintf(devCAConfig, caPEM, caKeyPEM),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("data.venafi_dev_ca.ca", "certificate", caPEM),
+					r.TestCheckResourceAttr("data.venafi_dev_ca.ca", "subject_dn", "CN=Terraform Dev CA"),
+					r.TestCheckResourceAttr("data.venafi_dev_ca.ca", "validity_hours", "48"),
+					r.TestCheckResourceAttr("venafi_certificate.dev", "chain", caPEM),
+					func(s *terraform.State) error {
+						attrs := s.RootModule().Resources["venafi_certificate.dev"].Primary.Attributes
+						block, _ := pem.Decode([]byte(attrs["certificate"]))
+						cert, err := x509.ParseCertificate(block.Bytes)
+						if err != nil {
+							return err
+						}
+						roots := x509.NewCertPool()
+						roots.AppendCertsFromPEM([]byte(caPEM))
+						_, err = cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "ca.venafi.example"})
+						if err != nil {
+							return fmt.Errorf("certificate isn't issued by the dev CA: %s", err)
+						}
+						if cert.NotAfter.Sub(cert.NotBefore) != 48*time.Hour {
+							return fmt.Errorf("expected 48 hours validity, got %s", cert.NotAfter.Sub(cert.NotBefore))
+						}
+						return nil
+					},
+				),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(devCAConfig, caPEM, otherKeyPEM),
+				ExpectError: regexp.MustCompile("dev_ca_private_key doesn't match dev_ca_certificate"),
+			},
+		},
+	})
+}
+
+func TestDevDefaultCA(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: `
+provider "venafi" {
+  dev_mode = true
+}
+data "venafi_dev_ca" "ca" {}`,
+				Check: func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["data.venafi_dev_ca.ca"].Primary.Attributes
+					if attrs["certificate"] != strings.TrimSpace(devTestCACertPEM)+"\n" {
+						return fmt.Errorf("expected vcert test mode CA, got %s", attrs["subject_dn"])
+					}
+					if attrs["validity_hours"] != "2160" {
+						return fmt.Errorf("expected 2160 hours validity, got %s", attrs["validity_hours"])
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
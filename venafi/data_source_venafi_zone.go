@@ -0,0 +1,193 @@
+package venafi
+
+import (
+	"crypto/x509"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"log"
+)
+
+func dataSourceVenafiZone() *schema.Resource {
+	regexList := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: description,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		}
+	}
+	return &schema.Resource{
+		Read: dataSourceVenafiZoneRead,
+
+		Schema: map[string]*schema.Schema{
+			"zone": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "DN of the Venafi Platform policy folder or name of the Venafi Cloud zone. Provider zone is used when empty.",
+			},
+			"organization": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Default organization of the zone",
+			},
+			"organizational_unit": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Default organizational units of the zone",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"country": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Default country of the zone",
+			},
+			"province": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Default state or province of the zone",
+			},
+			"locality": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Default locality of the zone",
+			},
+			"hash_algorithm": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Signature algorithm used for requests, e.g. SHA256-RSA",
+			},
+			"custom_attribute_values": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Custom attribute values of the zone",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"subject_cn_regexes": regexList("Regular expressions allowed for the subject common name"),
+			"subject_o_regexes":  regexList("Regular expressions allowed for the subject organization"),
+			"subject_ou_regexes": regexList("Regular expressions allowed for the subject organizational unit"),
+			"subject_st_regexes": regexList("Regular expressions allowed for the subject state or province"),
+			"subject_l_regexes":  regexList("Regular expressions allowed for the subject locality"),
+			"subject_c_regexes":  regexList("Regular expressions allowed for the subject country"),
+			"dns_san_regexes":    regexList("Regular expressions allowed for DNS names"),
+			"ip_san_regexes":     regexList("Regular expressions allowed for IP addresses"),
+			"email_san_regexes":  regexList("Regular expressions allowed for email addresses"),
+			"uri_san_regexes":    regexList("Regular expressions allowed for URIs"),
+			"upn_san_regexes":    regexList("Regular expressions allowed for user principal names"),
+			"allowed_key_configurations": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Key types allowed by the zone with their sizes or curves",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"key_type": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"key_sizes": &schema.Schema{
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeInt},
+						},
+						"key_curves": &schema.Schema{
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+			"allow_wildcards": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether wildcard certificates are allowed",
+			},
+			"allow_key_reuse": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether renewals may reuse the private key",
+			},
+		},
+	}
+}
+
+func dataSourceVenafiZoneRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	zone := d.Get("zone").(string)
+	if zone == "" {
+		zone = config.vcert.Zone
+	}
+	log.Printf("[DEBUG] Reading configuration of zone %s", zone)
+	cl, err := config.newConnector()
+	if err != nil {
+		return err
+	}
+	var zoneConfig *endpoint.ZoneConfiguration
+	err = callContext(config.stop(), func() (err error) {
+		zoneConfig, err = cl.ReadZoneConfiguration(zone)
+		return
+	})
+	if err != nil {
+		return newDiagnostic(config.withZone(zone), "error reading zone configuration", err)
+	}
+	var policy *endpoint.Policy
+	err = callContext(config.stop(), func() (err error) {
+		policy, err = cl.ReadPolicyConfiguration(zone)
+		return
+	})
+	if err != nil {
+		return newDiagnostic(config.withZone(zone), "error reading zone policy", err)
+	}
+
+	hashAlgorithm := ""
+	if zoneConfig.HashAlgorithm != x509.UnknownSignatureAlgorithm {
+		hashAlgorithm = zoneConfig.HashAlgorithm.String()
+	}
+	attributes := map[string]interface{}{
+		"zone":                       zone,
+		"organization":               zoneConfig.Organization,
+		"organizational_unit":        zoneConfig.OrganizationalUnit,
+		"country":                    zoneConfig.Country,
+		"province":                   zoneConfig.Province,
+		"locality":                   zoneConfig.Locality,
+		"hash_algorithm":             hashAlgorithm,
+		"custom_attribute_values":    zoneConfig.CustomAttributeValues,
+		"subject_cn_regexes":         policy.SubjectCNRegexes,
+		"subject_o_regexes":          policy.SubjectORegexes,
+		"subject_ou_regexes":         policy.SubjectOURegexes,
+		"subject_st_regexes":         policy.SubjectSTRegexes,
+		"subject_l_regexes":          policy.SubjectLRegexes,
+		"subject_c_regexes":          policy.SubjectCRegexes,
+		"dns_san_regexes":            policy.DnsSanRegExs,
+		"ip_san_regexes":             policy.IpSanRegExs,
+		"email_san_regexes":          policy.EmailSanRegExs,
+		"uri_san_regexes":            policy.UriSanRegExs,
+		"upn_san_regexes":            policy.UpnSanRegExs,
+		"allowed_key_configurations": flattenAllowedKeyConfigurations(policy.AllowedKeyConfigurations),
+		"allow_wildcards":            policy.AllowWildcards,
+		"allow_key_reuse":            policy.AllowKeyReuse,
+	}
+	for k, v := range attributes {
+		if err = d.Set(k, v); err != nil {
+			return fmt.Errorf("error setting %s: %s", k, err)
+		}
+	}
+	d.SetId(zone)
+	return nil
+}
+
+func flattenAllowedKeyConfigurations(configurations []endpoint.AllowedKeyConfiguration) []interface{} {
+	result := make([]interface{}, 0, len(configurations))
+	for _, c := range configurations {
+		curves := make([]string, 0, len(c.KeyCurves))
+		for _, curve := range c.KeyCurves {
+			curves = append(curves, curve.String())
+		}
+		result = append(result, map[string]interface{}{
+			"key_type":   c.KeyType.String(),
+			"key_sizes":  c.KeySizes,
+			"key_curves": curves,
+		})
+	}
+	return result
+}
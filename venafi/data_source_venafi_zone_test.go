@@ -0,0 +1,50 @@
+package venafi
+
+import (
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"testing"
+)
+
+const dev_zone_config = `
+provider "venafi" {
+  alias = "dev"
+  dev_mode = true
+}
+data "venafi_zone" "dev" {
+  provider = "venafi.dev"
+  zone = "dev-zone"
+}`
+
+func TestDevZone(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: dev_zone_config,
+				Check: func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["data.venafi_zone.dev"].Primary.Attributes
+					expected := map[string]string{
+						"zone":                                      "dev-zone",
+						"allow_key_reuse":                           "true",
+						"allow_wildcards":                           "true",
+						"subject_cn_regexes.0":                      ".*",
+						"dns_san_regexes.0":                         ".*",
+						"allowed_key_configurations.#":              "2",
+						"allowed_key_configurations.0.key_type":     "RSA",
+						"allowed_key_configurations.0.key_sizes.#":  "5",
+						"allowed_key_configurations.1.key_type":     "ECDSA",
+						"allowed_key_configurations.1.key_curves.#": "4",
+					}
+					for k, v := range expected {
+						if attrs[k] != v {
+							return fmt.Errorf("expected %s to be %s, got %s", k, v, attrs[k])
+						}
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
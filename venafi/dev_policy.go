@@ -0,0 +1,266 @@
+package venafi
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/certificate"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"regexp"
+	"strings"
+)
+
+// devPolicy is enforced by the dev mode connector like a zone policy of Venafi Platform or Cloud
+type devPolicy struct {
+	endpoint.Policy
+	//restrictKeys is set when allowed_key_configurations are configured, ED25519 has no vcert key type
+	//so it's allowed separately from AllowedKeyConfigurations
+	restrictKeys bool
+	allowED25519 bool
+}
+
+func devPolicySchema() *schema.Schema {
+	regexList := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeList,
+			Optional:    true,
+			Description: description,
+			Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: validateRegex},
+		}
+	}
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: `Policy enforced in dev mode. Values of a restricted field must match one of its regular expressions.`,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"subject_cn_regexes": regexList("Regular expressions allowed for the subject common name"),
+				"subject_o_regexes":  regexList("Regular expressions allowed for the subject organization"),
+				"subject_ou_regexes": regexList("Regular expressions allowed for the subject organizational unit"),
+				"subject_st_regexes": regexList("Regular expressions allowed for the subject state or province"),
+				"subject_l_regexes":  regexList("Regular expressions allowed for the subject locality"),
+				"subject_c_regexes":  regexList("Regular expressions allowed for the subject country"),
+				"dns_san_regexes":    regexList("Regular expressions allowed for DNS names"),
+				"ip_san_regexes":     regexList("Regular expressions allowed for IP addresses"),
+				"email_san_regexes":  regexList("Regular expressions allowed for email addresses"),
+				"allowed_key_configurations": &schema.Schema{
+					Type:        schema.TypeList,
+					Optional:    true,
+					Description: "Allowed key types with their sizes or curves, every key is allowed when empty",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"key_type": &schema.Schema{
+								Type:         schema.TypeString,
+								Required:     true,
+								ValidateFunc: validateKeyAlgorithm,
+							},
+							"key_sizes": &schema.Schema{
+								Type:     schema.TypeList,
+								Optional: true,
+								Elem:     &schema.Schema{Type: schema.TypeInt},
+							},
+							"key_curves": &schema.Schema{
+								Type:     schema.TypeList,
+								Optional: true,
+								Elem:     &schema.Schema{Type: schema.TypeString, ValidateFunc: validateECDSACurve},
+							},
+						},
+					},
+				},
+				"allow_wildcards": &schema.Schema{
+					Type:     schema.TypeBool,
+					Optional: true,
+					Default:  true,
+				},
+				"allow_key_reuse": &schema.Schema{
+					Type:     schema.TypeBool,
+					Optional: true,
+					Default:  true,
+				},
+			},
+		},
+	}
+}
+
+func validateRegex(v interface{}, k string) (ws []string, errs []error) {
+	if _, err := regexp.Compile(v.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("%s is not a valid regular expression: %s", k, err))
+	}
+	return
+}
+
+// expandDevPolicy returns nil when no dev_policy is configured
+func expandDevPolicy(list []interface{}) *devPolicy {
+	if len(list) == 0 || list[0] == nil {
+		return nil
+	}
+	m := list[0].(map[string]interface{})
+	p := &devPolicy{}
+	p.SubjectCNRegexes = toStringSlice(m["subject_cn_regexes"].([]interface{}))
+	p.SubjectORegexes = toStringSlice(m["subject_o_regexes"].([]interface{}))
+	p.SubjectOURegexes = toStringSlice(m["subject_ou_regexes"].([]interface{}))
+	p.SubjectSTRegexes = toStringSlice(m["subject_st_regexes"].([]interface{}))
+	p.SubjectLRegexes = toStringSlice(m["subject_l_regexes"].([]interface{}))
+	p.SubjectCRegexes = toStringSlice(m["subject_c_regexes"].([]interface{}))
+	p.DnsSanRegExs = toStringSlice(m["dns_san_regexes"].([]interface{}))
+	p.IpSanRegExs = toStringSlice(m["ip_san_regexes"].([]interface{}))
+	p.EmailSanRegExs = toStringSlice(m["email_san_regexes"].([]interface{}))
+	p.AllowWildcards = m["allow_wildcards"].(bool)
+	p.AllowKeyReuse = m["allow_key_reuse"].(bool)
+
+	keys := m["allowed_key_configurations"].([]interface{})
+	p.restrictKeys = len(keys) > 0
+	for _, k := range keys {
+		key := k.(map[string]interface{})
+		switch key["key_type"].(string) {
+		case algorithmED25519:
+			p.allowED25519 = true
+		case algorithmRSA:
+			c := endpoint.AllowedKeyConfiguration{KeyType: certificate.KeyTypeRSA}
+			for _, size := range key["key_sizes"].([]interface{}) {
+				c.KeySizes = append(c.KeySizes, size.(int))
+			}
+			p.AllowedKeyConfigurations = append(p.AllowedKeyConfigurations, c)
+		case algorithmECDSA:
+			c := endpoint.AllowedKeyConfiguration{KeyType: certificate.KeyTypeECDSA}
+			for _, curve := range key["key_curves"].([]interface{}) {
+				var ec certificate.EllipticCurve
+				ec.Set(curve.(string))
+				c.KeyCurves = append(c.KeyCurves, ec)
+			}
+			p.AllowedKeyConfigurations = append(p.AllowedKeyConfigurations, c)
+		}
+	}
+	return p
+}
+
+// check returns the first violation of the policy by the request
+func (p *devPolicy) check(csr *x509.CertificateRequest) error {
+	ips := make([]string, 0, len(csr.IPAddresses))
+	for _, ip := range csr.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	fields := []struct {
+		name    string
+		key     string
+		regexes []string
+		values  []string
+	}{
+		{"common name", "subject_cn_regexes", p.SubjectCNRegexes, []string{csr.Subject.CommonName}},
+		{"organization", "subject_o_regexes", p.SubjectORegexes, csr.Subject.Organization},
+		{"organizational unit", "subject_ou_regexes", p.SubjectOURegexes, csr.Subject.OrganizationalUnit},
+		{"state", "subject_st_regexes", p.SubjectSTRegexes, csr.Subject.Province},
+		{"locality", "subject_l_regexes", p.SubjectLRegexes, csr.Subject.Locality},
+		{"country", "subject_c_regexes", p.SubjectCRegexes, csr.Subject.Country},
+		{"DNS name", "dns_san_regexes", p.DnsSanRegExs, csr.DNSNames},
+		{"IP address", "ip_san_regexes", p.IpSanRegExs, ips},
+		{"email address", "email_san_regexes", p.EmailSanRegExs, csr.EmailAddresses},
+	}
+	for _, f := range fields {
+		for _, v := range f.values {
+			if len(f.regexes) > 0 && !matchesAnyRegex(f.regexes, v) {
+				return fmt.Errorf("%s %q doesn't match dev_policy %s", f.name, v, f.key)
+			}
+		}
+	}
+	if !p.AllowWildcards {
+		for _, name := range append([]string{csr.Subject.CommonName}, csr.DNSNames...) {
+			if strings.HasPrefix(name, "*") {
+				return fmt.Errorf("wildcard %s isn't allowed by dev_policy", name)
+			}
+		}
+	}
+	if !p.keyAllowed(csr.PublicKey) {
+		return fmt.Errorf("key %s isn't allowed by dev_policy allowed_key_configurations", publicKeyDescription(csr.PublicKey))
+	}
+	return nil
+}
+
+func (p *devPolicy) keyAllowed(publicKey interface{}) bool {
+	if !p.restrictKeys {
+		return true
+	}
+	if _, ok := publicKey.(ed25519.PublicKey); ok {
+		return p.allowED25519
+	}
+	for _, c := range p.AllowedKeyConfigurations {
+		switch key := publicKey.(type) {
+		case *rsa.PublicKey:
+			if c.KeyType == certificate.KeyTypeRSA && (len(c.KeySizes) == 0 || containsInt(c.KeySizes, key.N.BitLen())) {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if c.KeyType != certificate.KeyTypeECDSA {
+				continue
+			}
+			if len(c.KeyCurves) == 0 {
+				return true
+			}
+			for _, curve := range c.KeyCurves {
+				if strings.Replace(key.Curve.Params().Name, "-", "", 1) == curve.String() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// endpointPolicy returns the policy as reported by ReadPolicyConfiguration, unrestricted fields allow everything
+func (p *devPolicy) endpointPolicy() *endpoint.Policy {
+	all := func(regexes []string) []string {
+		if len(regexes) == 0 {
+			return []string{".*"}
+		}
+		return regexes
+	}
+	policy := p.Policy
+	for _, regexes := range []*[]string{
+		&policy.SubjectCNRegexes, &policy.SubjectORegexes, &policy.SubjectOURegexes, &policy.SubjectSTRegexes,
+		&policy.SubjectLRegexes, &policy.SubjectCRegexes, &policy.DnsSanRegExs, &policy.IpSanRegExs,
+		&policy.EmailSanRegExs, &policy.UriSanRegExs, &policy.UpnSanRegExs,
+	} {
+		*regexes = all(*regexes)
+	}
+	if !p.restrictKeys {
+		policy.AllowedKeyConfigurations = []endpoint.AllowedKeyConfiguration{
+			{KeyType: certificate.KeyTypeRSA, KeySizes: certificate.AllSupportedKeySizes()},
+			{KeyType: certificate.KeyTypeECDSA, KeyCurves: certificate.AllSupportedCurves()},
+		}
+	}
+	return &policy
+}
+
+func matchesAnyRegex(regexes []string, value string) bool {
+	for _, r := range regexes {
+		if matched, err := regexp.MatchString(r, value); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, v int) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func publicKeyDescription(publicKey interface{}) string {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + strings.Replace(key.Curve.Params().Name, "-", "", 1)
+	case ed25519.PublicKey:
+		return algorithmED25519
+	}
+	return "of unknown type"
+}
@@ -0,0 +1,117 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform/helper/schema"
+	"math/rand"
+	"time"
+)
+
+const devStatusPending = "Pending approval (dev mode)"
+
+// devPollInterval is how often dev mode checks a pending certificate, like vcert does with Venafi Platform
+var devPollInterval = 2 * time.Second
+
+// devSimulation makes the dev mode connector behave like a slow, strict or flaky Venafi Platform
+type devSimulation struct {
+	pendingPolls       int
+	timeout            bool
+	policyError        string
+	transientErrorRate float64
+}
+
+func devSimulationSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Failures simulated in dev mode to test approval workflows and unreliable backends",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"pending_polls": &schema.Schema{
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Description:  "Number of polls a requested or renewed certificate stays pending before it's issued",
+					ValidateFunc: validateNotNegative,
+				},
+				"timeout": &schema.Schema{
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Description: "Certificates stay pending and their retrieval times out",
+				},
+				"policy_error": &schema.Schema{
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Reject every request and renewal with this policy error",
+				},
+				"transient_error_rate": &schema.Schema{
+					Type:         schema.TypeFloat,
+					Optional:     true,
+					Description:  "Share of operations failing with a transient error, from 0 to 1",
+					ValidateFunc: validateRate,
+				},
+			},
+		},
+	}
+}
+
+func validateNotNegative(v interface{}, k string) (ws []string, errs []error) {
+	if v.(int) < 0 {
+		errs = append(errs, fmt.Errorf("%s can't be negative, got %d", k, v))
+	}
+	return
+}
+
+func validateRate(v interface{}, k string) (ws []string, errs []error) {
+	if rate := v.(float64); rate < 0 || rate > 1 {
+		errs = append(errs, fmt.Errorf("%s must be between 0 and 1, got %g", k, rate))
+	}
+	return
+}
+
+// expandDevSimulation returns nil when no dev_simulation is configured
+func expandDevSimulation(list []interface{}) *devSimulation {
+	if len(list) == 0 || list[0] == nil {
+		return nil
+	}
+	m := list[0].(map[string]interface{})
+	return &devSimulation{
+		pendingPolls:       m["pending_polls"].(int),
+		timeout:            m["timeout"].(bool),
+		policyError:        m["policy_error"].(string),
+		transientErrorRate: m["transient_error_rate"].(float64),
+	}
+}
+
+// transientError fails the operation at transient_error_rate
+func (s *devSimulation) transientError(operation string) error {
+	if s == nil || s.transientErrorRate == 0 || rand.Float64() >= s.transientErrorRate {
+		return nil
+	}
+	return fmt.Errorf("%s failed with simulated transient error in dev mode: 503 Service Unavailable", operation)
+}
+
+// requestError is the simulated error of a certificate request or renewal
+func (s *devSimulation) requestError(operation string) error {
+	if s == nil {
+		return nil
+	}
+	if err := s.transientError(operation); err != nil {
+		return err
+	}
+	if s.policyError != "" {
+		return fmt.Errorf("%s rejected by simulated policy in dev mode: %s", operation, s.policyError)
+	}
+	return nil
+}
+
+// pending returns the number of polls a new certificate stays pending, -1 when it's never issued
+func (s *devSimulation) pending() int {
+	switch {
+	case s == nil:
+		return 0
+	case s.timeout:
+		return -1
+	}
+	return s.pendingPolls
+}
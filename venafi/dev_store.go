@@ -0,0 +1,125 @@
+package venafi
+
+import (
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// devCertificate is a certificate tracked by the dev mode store
+type devCertificate struct {
+	CSR              string `json:",omitempty"`
+	Certificate      string `json:",omitempty"`
+	Thumbprint       string `json:",omitempty"`
+	Serial           string `json:",omitempty"`
+	Imported         bool   `json:",omitempty"`
+	Revoked          bool   `json:",omitempty"`
+	RevocationReason string `json:",omitempty"`
+	Disabled         bool   `json:",omitempty"`
+	//PendingPolls is the number of retrievals left until the certificate is issued
+	PendingPolls int `json:",omitempty"`
+}
+
+func (c *devCertificate) setCertificate(cert *x509.Certificate, certPEM string) {
+	thumbprint := sha1.Sum(cert.Raw)
+	c.Certificate = certPEM
+	c.Thumbprint = strings.ToUpper(hex.EncodeToString(thumbprint[:]))
+	c.Serial = cert.SerialNumber.Text(16)
+}
+
+type devStoreData struct {
+	//Certificates are keyed by pickup ID or DN of imported certificates
+	Certificates map[string]*devCertificate
+	LastVaultID  int
+}
+
+// find looks a certificate up by its ID or thumbprint
+func (data *devStoreData) find(id string, thumbprint string) (string, *devCertificate) {
+	if id != "" {
+		return id, data.Certificates[id]
+	}
+	thumbprint = normalizeThumbprint(thumbprint)
+	for id, c := range data.Certificates {
+		if c.Thumbprint == thumbprint {
+			return id, c
+		}
+	}
+	return "", nil
+}
+
+// devStore tracks dev mode certificates in memory or in a JSON file when path is set
+type devStore struct {
+	sync.Mutex
+	path string
+	data devStoreData
+}
+
+var (
+	devStoresLock sync.Mutex
+	//devStores keeps stores for the life of the plugin process, so provider configurations share them
+	devStores = map[string]*devStore{}
+)
+
+func openDevStore(path string) *devStore {
+	devStoresLock.Lock()
+	defer devStoresLock.Unlock()
+	s, ok := devStores[path]
+	if !ok {
+		s = &devStore{path: path, data: devStoreData{Certificates: map[string]*devCertificate{}}}
+		devStores[path] = s
+	}
+	return s
+}
+
+// update loads the store, calls f and saves the store when f succeeds
+func (s *devStore) update(f func(data *devStoreData) error) error {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if err := f(&s.data); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+func (s *devStore) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error reading dev_store_file: %s", err)
+	}
+	s.data = devStoreData{}
+	if err = json.Unmarshal(data, &s.data); err != nil {
+		return fmt.Errorf("error parsing dev_store_file %s: %s", s.path, err)
+	}
+	if s.data.Certificates == nil {
+		s.data.Certificates = map[string]*devCertificate{}
+	}
+	return nil
+}
+
+func (s *devStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(s.path, data, 0600, -1, -1); err != nil {
+		return fmt.Errorf("error writing dev_store_file: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package venafi
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"github.com/Venafi/vcert"
+	"github.com/Venafi/vcert/pkg/certificate"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const devPolicyConfig = `
+provider "venafi" {
+  dev_mode = true
+  dev_policy {
+    subject_cn_regexes = ["^[a-z0-9-]+\\.venafi\\.example$"]
+    allow_wildcards = false
+    allowed_key_configurations {
+      key_type = "RSA"
+      key_sizes = [2048, 4096]
+    }
+  }
+}
+resource "venafi_certificate" "dev" {
+  common_name = "%s"
+  algorithm = "%s"
+}
+data "venafi_zone" "dev" {
+  zone = "dev-zone"
+}`
+
+func TestDevPolicy(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: fmt.Sprintf(devPolicyConfig, "web.venafi.example", "RSA"),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate.dev", "subject_dn", "CN=web.venafi.example"),
+					r.TestCheckResourceAttr("data.venafi_zone.dev", "subject_cn_regexes.0", "^[a-z0-9-]+\\.venafi\\.example$"),
+					r.TestCheckResourceAttr("data.venafi_zone.dev", "dns_san_regexes.0", ".*"),
+					r.TestCheckResourceAttr("data.venafi_zone.dev", "allow_wildcards", "false"),
+					r.TestCheckResourceAttr("data.venafi_zone.dev", "allowed_key_configurations.#", "1"),
+					r.TestCheckResourceAttr("data.venafi_zone.dev", "allowed_key_configurations.0.key_sizes.#", "2"),
+				),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(devPolicyConfig, "web.example.com", "RSA"),
+				ExpectError: regexp.MustCompile(`common name "web.example.com" doesn't match dev_policy subject_cn_regexes`),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(devPolicyConfig, "web.venafi.example", "ECDSA"),
+				ExpectError: regexp.MustCompile("key ECDSA P521 isn't allowed by dev_policy allowed_key_configurations"),
+			},
+			r.TestStep{
+				//key reuse is checked when the certificate is created, not while planning
+				Config: strings.NewReplacer(
+					"allow_wildcards = false", "allow_wildcards = false\n    allow_key_reuse = false",
+					`algorithm = "RSA"`, "algorithm = \"RSA\"\n  reuse_private_key = true",
+				).Replace(fmt.Sprintf(devPolicyConfig, "reuse.venafi.example", "RSA")),
+				ExpectError: regexp.MustCompile("reuse_private_key is set but policy of zone .* doesn't allow key reuse"),
+			},
+		},
+	})
+}
+
+// readDevStoreFile returns the certificates saved in a dev_store_file
+func readDevStoreFile(path string) (map[string]*devCertificate, error) {
+	js, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data devStoreData
+	if err = json.Unmarshal(js, &data); err != nil {
+		return nil, err
+	}
+	return data.Certificates, nil
+}
+
+const devStoreConfig = `
+provider "venafi" {
+  dev_mode = true
+  dev_store_file = "%s"
+}
+resource "venafi_certificate" "dev" {
+  common_name = "store.venafi.example"
+  expiration_window = 2150
+  revoke_on_destroy = true
+  revocation_reason = "superseded"
+}`
+
+func TestDevStoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "venafi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.ToSlash(filepath.Join(dir, "dev-store.json"))
+	config := fmt.Sprintf(devStoreConfig, path)
+
+	var id, pickupID, serial string
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			certificates, err := readDevStoreFile(path)
+			if err != nil {
+				return err
+			}
+			c := certificates[pickupID]
+			if c == nil || !c.Revoked || c.RevocationReason != "superseded" {
+				return fmt.Errorf("certificate %s wasn't revoked on destroy: %+v", id, c)
+			}
+			return nil
+		},
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+				Check: func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["venafi_certificate.dev"].Primary.Attributes
+					id, pickupID, serial = attrs["id"], attrs["certificate_dn"], attrs["serial_number"]
+					if n, _ := new(big.Int).SetString(serial, 10); n == nil || n.Text(16) != id {
+						return fmt.Errorf("ID %s isn't the serial number %s", id, serial)
+					}
+					certificates, err := readDevStoreFile(path)
+					if err != nil {
+						return err
+					}
+					if c := certificates[pickupID]; c == nil || c.Certificate != attrs["certificate"] {
+						return fmt.Errorf("certificate %s isn't saved in dev_store_file", id)
+					}
+					return nil
+				},
+			},
+			r.TestStep{
+				//every refresh renews the certificate because of the expiration window
+				Config: config,
+				Check: func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["venafi_certificate.dev"].Primary.Attributes
+					if attrs["id"] != id {
+						return fmt.Errorf("renewal changed ID from %s to %s", id, attrs["id"])
+					}
+					if attrs["serial_number"] == serial {
+						return fmt.Errorf("certificate %s was not renewed", serial)
+					}
+					certificates, err := readDevStoreFile(path)
+					if err != nil {
+						return err
+					}
+					if certificates[pickupID].Certificate != attrs["certificate"] {
+						return fmt.Errorf("renewed certificate isn't saved in dev_store_file")
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const devSimulationConfig = `
+provider "venafi" {
+  dev_mode = true
+  dev_simulation {
+    %s
+  }
+}
+resource "venafi_certificate" "dev" {
+  common_name = "simulation.venafi.example"
+}`
+
+func TestDevSimulation(t *testing.T) {
+	devPollInterval = 10 * time.Millisecond
+	defer func(interval time.Duration) { certificateRetrieveInterval = interval }(certificateRetrieveInterval)
+	certificateRetrieveInterval = 10 * time.Millisecond
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config:      fmt.Sprintf(devSimulationConfig, `policy_error = "Common name is locked to www.venafi.example"`),
+				ExpectError: regexp.MustCompile("certificate request rejected by simulated policy in dev mode: Common name is locked to www.venafi.example"),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(devSimulationConfig, "transient_error_rate = 1"),
+				ExpectError: regexp.MustCompile("certificate request failed with simulated transient error in dev mode"),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(devSimulationConfig, "timeout = true"),
+				ExpectError: regexp.MustCompile("Operation timed out. You may try retrieving the certificate later using Pickup ID"),
+			},
+			r.TestStep{
+				Config: fmt.Sprintf(devSimulationConfig, "pending_polls = 3\n    transient_error_rate = 0"),
+				Check:  r.TestCheckResourceAttr("venafi_certificate.dev", "subject_dn", "CN=simulation.venafi.example"),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(devSimulationConfig, "transient_error_rate = 2"),
+				ExpectError: regexp.MustCompile("transient_error_rate must be between 0 and 1, got 2"),
+			},
+		},
+	})
+}
+
+func TestDevRenewRevoke(t *testing.T) {
+	ca, err := newDevCA("", "", devDefaultValidityHours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &devBackend{ca: ca, simulation: &devSimulation{}, store: &devStore{data: devStoreData{Certificates: map[string]*devCertificate{}}}}
+	cl := newDevConnector(backend)
+	req := &certificate.Request{Subject: pkix.Name{CommonName: "renew.venafi.example"}, KeyType: certificate.KeyTypeECDSA, KeyCurve: certificate.EllipticCurveP256}
+	req.PrivateKey, _ = certificate.GenerateECDSAPrivateKey(req.KeyCurve)
+	if err = cl.GenerateRequest(nil, req); err != nil {
+		t.Fatal(err)
+	}
+	id, err := cl.RequestCertificate(req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := backend.store.data.Certificates[id].Serial
+
+	renewedID, err := cl.RenewCertificate(&certificate.RenewalRequest{CertificateDN: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewedID != id || backend.store.data.Certificates[id].Serial == serial {
+		t.Fatalf("certificate %s wasn't renewed under the same ID, got %s", id, renewedID)
+	}
+
+	if err = cl.RevokeCertificate(&certificate.RevocationRequest{CertificateDN: id, Reason: "superseded"}); err != nil {
+		t.Fatal(err)
+	}
+	if c := backend.store.data.Certificates[id]; !c.Revoked || c.RevocationReason != "superseded" {
+		t.Fatalf("certificate %s wasn't revoked: %+v", id, c)
+	}
+	if _, err = cl.RenewCertificate(&certificate.RenewalRequest{CertificateDN: id}); err == nil || !strings.Contains(err.Error(), "is revoked") {
+		t.Fatalf("expected revoked certificate not to be renewed, got %v", err)
+	}
+}
+
+func TestDevSimulationPending(t *testing.T) {
+	ca, err := newDevCA("", "", devDefaultValidityHours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &devBackend{ca: ca, simulation: &devSimulation{pendingPolls: 2}, store: &devStore{data: devStoreData{Certificates: map[string]*devCertificate{}}}}
+	cl := newDevConnector(backend)
+	req := &certificate.Request{Subject: pkix.Name{CommonName: "pending.venafi.example"}, KeyType: certificate.KeyTypeECDSA, KeyCurve: certificate.EllipticCurveP256}
+	req.PrivateKey, _ = certificate.GenerateECDSAPrivateKey(req.KeyCurve)
+	if err := cl.GenerateRequest(nil, req); err != nil {
+		t.Fatal(err)
+	}
+	id, err := cl.RequestCertificate(req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = cl.RetrieveCertificate(&certificate.Request{PickupID: id})
+		if _, ok := err.(endpoint.ErrCertificatePending); !ok {
+			t.Fatalf("poll %d: expected pending certificate, got %v", i, err)
+		}
+	}
+	if _, err = cl.RetrieveCertificate(&certificate.Request{PickupID: id}); err != nil {
+		t.Fatalf("certificate wasn't issued after 2 polls: %s", err)
+	}
+}
+
+const devRetrieveTimeoutConfig = `
+provider "venafi" {
+  dev_mode = true
+  dev_simulation {
+    pending_polls = 1000
+  }
+}
+resource "venafi_certificate" "dev" {
+  common_name = "pending.venafi.example"
+  timeouts {
+    create = "1s"
+  }
+}`
+
+func TestCertificateRetrieveTimeout(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config:      devRetrieveTimeoutConfig,
+				ExpectError: regexp.MustCompile(`timed out after 1s waiting for certificate with pickup ID \S+, last status: Pending approval \(dev mode\)`),
+			},
+		},
+	})
+}
+
+func TestCertificateRetrieveInterrupted(t *testing.T) {
+	ca, err := newDevCA("", "", devDefaultValidityHours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &devBackend{ca: ca, simulation: &devSimulation{pendingPolls: 1000}, store: &devStore{data: devStoreData{Certificates: map[string]*devCertificate{}}}}
+	cl := newDevConnector(backend)
+	req := &certificate.Request{Subject: pkix.Name{CommonName: "interrupted.venafi.example"}, KeyType: certificate.KeyTypeECDSA, KeyCurve: certificate.EllipticCurveP256}
+	req.PrivateKey, _ = certificate.GenerateECDSAPrivateKey(req.KeyCurve)
+	if err = cl.GenerateRequest(nil, req); err != nil {
+		t.Fatal(err)
+	}
+	id, err := cl.RequestCertificate(req, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &providerConfig{vcert: &vcert.Config{ConnectorType: endpoint.ConnectorTypeFake}, dev: backend}
+	stopContext, stop := context.WithCancel(context.Background())
+	config.stopContext = stopContext
+	ctx, cancel := config.context(time.Hour)
+	defer cancel()
+	time.AfterFunc(100*time.Millisecond, stop)
+	start := time.Now()
+	_, err = retrieveCertificate(ctx, config, cl, id, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "interrupted while waiting for certificate with pickup ID "+id) {
+		t.Fatalf("expected interruption with pickup ID, got %v", err)
+	}
+	if time.Since(start) > certificateRetrieveInterval {
+		t.Fatalf("retrieval wasn't interrupted right away, it took %s", time.Since(start))
+	}
+}
+
+func TestCallContextInterrupted(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := callContext(ctx, func() error {
+		<-release
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected blocked call to time out, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("blocked call wasn't given up on right away, it took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,137 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diagnostic is an error of a Venafi operation with the context needed to fix it. Terraform shows errors as text,
+// so the context is rendered as indented lines below the message.
+type diagnostic struct {
+	summary string
+	err     error
+	//attribute is the configuration attribute which most likely needs a fix
+	attribute string
+	backend   string
+	zone      string
+	pickupID  string
+	status    string
+	hint      string
+}
+
+func (d *diagnostic) Error() string {
+	var b strings.Builder
+	b.WriteString(d.summary)
+	if d.err != nil {
+		fmt.Fprintf(&b, ": %s", strings.TrimSpace(d.err.Error()))
+	}
+	for _, field := range [][2]string{
+		{"attribute", d.attribute},
+		{"backend", d.backend},
+		{"zone", d.zone},
+		{"pickup ID", d.pickupID},
+		{"HTTP status", d.status},
+		{"suggestion", d.hint},
+	} {
+		if field[1] != "" {
+			fmt.Fprintf(&b, "\n  %s: %s", field[0], field[1])
+		}
+	}
+	return b.String()
+}
+
+var httpStatusPattern = regexp.MustCompile(`(?i)status:? (\d{3})\b`)
+
+// httpStatus finds the HTTP status in errors of vcert and restClient, which only report it in the message
+func httpStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	m := httpStatusPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	code, _ := strconv.Atoi(m[1])
+	return code
+}
+
+// newDiagnostic describes err of an operation with the configured backend and zone, and suggests a fix for common causes
+func newDiagnostic(config *providerConfig, summary string, err error) *diagnostic {
+	d := &diagnostic{summary: summary, err: err, zone: config.vcert.Zone}
+	switch config.vcert.ConnectorType {
+	case endpoint.ConnectorTypeTPP:
+		d.backend = fmt.Sprintf("Venafi Platform at %s", config.vcert.BaseUrl)
+	case endpoint.ConnectorTypeCloud:
+		url := config.vcert.BaseUrl
+		if url == "" {
+			url = cloudDefaultURL
+		}
+		d.backend = fmt.Sprintf("Venafi Cloud at %s", url)
+	case endpoint.ConnectorTypeFake:
+		d.backend = "dev mode"
+	}
+	code := httpStatus(err)
+	if code != 0 {
+		d.status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+	}
+	if err == nil {
+		return d
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case code == http.StatusUnauthorized && config.vcert.ConnectorType == endpoint.ConnectorTypeCloud:
+		d.attribute = "api_key"
+		d.hint = "check that api_key is a valid Venafi Cloud API key"
+	case code == http.StatusUnauthorized:
+		d.attribute = "tpp_username"
+		d.hint = `check tpp_username and tpp_password, local Venafi Platform users need the "local:" prefix`
+	case code == http.StatusForbidden:
+		d.attribute = "zone"
+		d.hint = fmt.Sprintf("grant the user access to zone %q or use another zone", d.zone)
+	case strings.Contains(message, "zone"):
+		d.attribute = "zone"
+		d.hint = fmt.Sprintf(`check that zone %q exists, Venafi Platform zones are policy folders relative to \VED\Policy and Venafi Cloud zones are zone tags`, d.zone)
+	case strings.Contains(message, "x509:"):
+		d.attribute = "trust_bundle"
+		d.hint = "set trust_bundle to the PEM certificate of the CA which issued the server certificate"
+	case strings.Contains(message, "dial tcp") || strings.Contains(message, "no such host") || strings.Contains(message, "connection refused"):
+		d.attribute = "url"
+		d.hint = "check that url is reachable from this machine"
+	}
+	return d
+}
+
+// credentialsError tells which credentials are missing when providerConfigure can't choose a connector
+func credentialsError(url, tppUser, tppPassword string) error {
+	switch {
+	case tppUser != "" && tppPassword == "":
+		return &diagnostic{
+			summary:   "tpp_password is required with tpp_username",
+			attribute: "tpp_password",
+			backend:   "Venafi Platform",
+			hint:      "set tpp_password or the VENAFI_PASS environment variable",
+		}
+	case tppUser == "" && tppPassword != "":
+		return &diagnostic{
+			summary:   "tpp_username is required with tpp_password",
+			attribute: "tpp_username",
+			backend:   "Venafi Platform",
+			hint:      "set tpp_username or the VENAFI_USER environment variable",
+		}
+	case url != "":
+		return &diagnostic{
+			summary:   fmt.Sprintf("no credentials for %s", url),
+			attribute: "tpp_username",
+			hint:      "set tpp_username and tpp_password for Venafi Platform or api_key for Venafi Cloud",
+		}
+	}
+	return &diagnostic{
+		summary: "no Venafi credentials configured",
+		hint:    "set url, tpp_username and tpp_password for Venafi Platform, api_key for Venafi Cloud or dev_mode = true to issue certificates locally",
+	}
+}
@@ -0,0 +1,52 @@
+package venafi
+
+import (
+	"errors"
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"regexp"
+	"testing"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	cases := map[string]int{
+		"Unexpected status code on TPP Certificate Request. Status: 400 Bad Request. Body: {}": 400,
+		"Unexpected status code on Venafi Cloud registration. Status: 401 Unauthorized\n":      401,
+		"unexpected status 403 Forbidden for POST Config/Read: {}":                             403,
+		"could not read zone configuration: Invalid status: 404 Not Found":                     404,
+		"Failed to retrieve certificate. Status: {FAILED}":                                     0,
+	}
+	for message, expected := range cases {
+		if got := httpStatus(errors.New(message)); got != expected {
+			t.Errorf("expected status %d in %q, got %d", expected, message, got)
+		}
+	}
+}
+
+const credentialsConfig = `
+provider "venafi" {
+  %s
+}
+resource "venafi_certificate" "test" {
+  common_name = "credentials.venafi.example"
+}`
+
+func TestProviderCredentialsDiagnostics(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config:      fmt.Sprintf(credentialsConfig, `url = "https://tpp.venafi.example/vedsdk"`+"\n"+`tpp_username = "admin"`),
+				ExpectError: regexp.MustCompile(`(?s)tpp_password is required with tpp_username.*attribute: tpp_password.*backend: Venafi Platform.*suggestion: set tpp_password or the VENAFI_PASS environment variable`),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(credentialsConfig, `tpp_username = "admin"`+"\n"+`tpp_password = "secret"`),
+				ExpectError: regexp.MustCompile(`(?s)url is required for Venafi Platform.*attribute: url`),
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(credentialsConfig, `zone = "devops"`),
+				ExpectError: regexp.MustCompile(`(?s)no Venafi credentials configured.*suggestion: set url, tpp_username and tpp_password for Venafi Platform, api_key for Venafi Cloud or dev_mode = true`),
+			},
+		},
+	})
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+// +build !windows
+
+package venafi
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// lookupFileOwner resolves user and group names or IDs, returning -1 for the ones which are empty.
+func lookupFileOwner(owner string, group string) (uid int, gid int, err error) {
+	uid, gid = -1, -1
+	if owner != "" {
+		if uid, err = strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, fmt.Errorf("error looking up owner %s: %s", owner, err)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, fmt.Errorf("error looking up group %s: %s", group, err)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// fileModeMatches reports whether the file has the given permissions.
+func fileModeMatches(info os.FileInfo, mode os.FileMode) bool {
+	return info.Mode().Perm() == mode
+}
+
+// fileOwnedBy reports whether the file has the given owner and group, ignoring the ones which are -1.
+func fileOwnedBy(info os.FileInfo, uid int, gid int) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+	return (uid == -1 || int(stat.Uid) == uid) && (gid == -1 || int(stat.Gid) == gid)
+}
@@ -0,0 +1,24 @@
+package venafi
+
+import (
+	"fmt"
+	"os"
+)
+
+// lookupFileOwner refuses ownership settings because Windows files have no POSIX owner and group.
+func lookupFileOwner(owner string, group string) (uid int, gid int, err error) {
+	if owner != "" || group != "" {
+		return -1, -1, fmt.Errorf("owner and group are not supported on Windows")
+	}
+	return -1, -1, nil
+}
+
+// fileModeMatches compares only the read-only attribute, the one permission Windows files have. Go reports the other
+// permission bits as set, so they never match a configured mode like 0600.
+func fileModeMatches(info os.FileInfo, mode os.FileMode) bool {
+	return info.Mode().Perm()&0200 == mode&0200
+}
+
+func fileOwnedBy(info os.FileInfo, uid int, gid int) bool {
+	return true
+}
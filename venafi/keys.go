@@ -0,0 +1,262 @@
+package venafi
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/certificate"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"sort"
+)
+
+const (
+	algorithmRSA     = "RSA"
+	algorithmECDSA   = "ECDSA"
+	algorithmED25519 = "ED25519"
+
+	minimalRSAKeySize = 2048
+
+	privateKeyFormatPKCS1          = "pkcs1"
+	privateKeyFormatPKCS8          = "pkcs8"
+	privateKeyFormatPKCS8Encrypted = "pkcs8-encrypted"
+)
+
+// supportedRSAKeySizes returns the RSA key sizes known to vcert extended with sizes the provider also accepts.
+// vcert is an external module and its certificate.AllSupportedKeySizes doesn't list 3072, which only matters for
+// keys generated by vcert. The provider generates keys and CSRs itself, so 3072 bit keys reach Venafi Platform,
+// Venafi Cloud and dev mode as an ordinary CSR and are checked against the zone policy there.
+func supportedRSAKeySizes() []int {
+	sizes := append([]int{3072}, certificate.AllSupportedKeySizes()...)
+	sort.Ints(sizes)
+	return sizes
+}
+
+func validateKeyAlgorithm(v interface{}, k string) (ws []string, errs []error) {
+	switch v.(string) {
+	case algorithmRSA, algorithmECDSA, algorithmED25519:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of %s, %s or %s, got %s", k, algorithmRSA, algorithmECDSA, algorithmED25519, v))
+	}
+	return
+}
+
+func validateRSAKeySize(v interface{}, k string) (ws []string, errs []error) {
+	size := v.(int)
+	for _, s := range supportedRSAKeySizes() {
+		if s == size {
+			return
+		}
+	}
+	errs = append(errs, fmt.Errorf("%s must be one of %v, got %d", k, supportedRSAKeySizes(), size))
+	return
+}
+
+func validateECDSACurve(v interface{}, k string) (ws []string, errs []error) {
+	switch v.(string) {
+	case "P224", "P256", "P384", "P521":
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of P224, P256, P384 or P521, got %s", k, v))
+	}
+	return
+}
+
+func validatePrivateKeyFormat(v interface{}, k string) (ws []string, errs []error) {
+	switch v.(string) {
+	case privateKeyFormatPKCS1, privateKeyFormatPKCS8, privateKeyFormatPKCS8Encrypted:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of %s, %s or %s, got %s", k, privateKeyFormatPKCS1, privateKeyFormatPKCS8, privateKeyFormatPKCS8Encrypted, v))
+	}
+	return
+}
+
+// checkPrivateKeyFormat verifies that key_password is set exactly when the format needs it. Encrypted pkcs1 keys are
+// refused because their legacy PEM encryption ("Proc-Type: 4,ENCRYPTED") uses MD5 to derive the key.
+func checkPrivateKeyFormat(format string, password string) error {
+	switch {
+	case (format == privateKeyFormatPKCS1 || format == "") && password != "":
+		return fmt.Errorf("key_password can't be used with private_key_format %s because of its weak legacy encryption, use %s", privateKeyFormatPKCS1, privateKeyFormatPKCS8Encrypted)
+	case format == privateKeyFormatPKCS8 && password != "":
+		return fmt.Errorf("key_password can't be used with private_key_format %s, use %s", privateKeyFormatPKCS8, privateKeyFormatPKCS8Encrypted)
+	case format == privateKeyFormatPKCS8Encrypted && password == "":
+		return fmt.Errorf("key_password is required for private_key_format %s", privateKeyFormatPKCS8Encrypted)
+	}
+	return nil
+}
+
+// checkKeyStrength rejects key parameters which are considered weak unless allowWeak is set.
+func checkKeyStrength(algorithm string, rsaBits int, curve string, allowWeak bool) error {
+	if allowWeak {
+		return nil
+	}
+	switch algorithm {
+	case algorithmRSA, "":
+		if rsaBits < minimalRSAKeySize {
+			return fmt.Errorf("RSA key size %d is weak, use at least %d bits or set allow_weak_keys", rsaBits, minimalRSAKeySize)
+		}
+	case algorithmECDSA:
+		if curve == "P224" {
+			return fmt.Errorf("ECDSA curve %s is weak, use P256 or stronger or set allow_weak_keys", curve)
+		}
+	}
+	return nil
+}
+
+// keyChanged reports whether the resource is created or one of the key attributes changes. Key strength and format are
+// checked only then, so resources whose keys were accepted by earlier versions still plan without changes.
+func keyChanged(d *schema.ResourceDiff, keys ...string) bool {
+	if d.Id() == "" {
+		return true
+	}
+	for _, k := range keys {
+		if d.HasChange(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// generateUserCSR creates a PEM encoded CSR for the request using a private key which vcert can't generate itself.
+func generateUserCSR(req *certificate.Request, privateKey interface{}) error {
+	req.CsrOrigin = certificate.UserProvidedCSR
+	req.PrivateKey = privateKey
+	err := certificate.GenerateRequest(req, privateKey)
+	if err != nil {
+		return err
+	}
+	req.CSR = pem.EncodeToMemory(certificate.GetCertificateRequestPEMBlock(req.CSR))
+	return nil
+}
+
+// parseCSR decodes a PEM encoded CSR and verifies its signature.
+func parseCSR(csrPEM string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(csrPEM))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("no PEM encoded CERTIFICATE REQUEST found")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err = csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid CSR signature: %s", err)
+	}
+	return csr, nil
+}
+
+func validateCSRPEM(v interface{}, k string) (ws []string, errs []error) {
+	if _, err := parseCSR(v.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("error parsing %s: %s", k, err))
+	}
+	return
+}
+
+// setProvidedCSR makes the request use a CSR generated outside of the provider, taking subject and SAN from it.
+func setProvidedCSR(req *certificate.Request, csrPEM string) error {
+	csr, err := parseCSR(csrPEM)
+	if err != nil {
+		return err
+	}
+	if req.Subject.CommonName != csr.Subject.CommonName {
+		return fmt.Errorf("common name %s doesn't match common name %s of the CSR", req.Subject.CommonName, csr.Subject.CommonName)
+	}
+	req.CsrOrigin = certificate.UserProvidedCSR
+	req.CSR = []byte(csrPEM)
+	req.Subject = csr.Subject
+	req.DNSNames = csr.DNSNames
+	req.EmailAddresses = csr.EmailAddresses
+	req.IPAddresses = csr.IPAddresses
+	return nil
+}
+
+// parsePrivateKey reads an unencrypted PKCS#1, SEC 1 or PKCS#8 PEM private key.
+func parsePrivateKey(keyPEM []byte) (interface{}, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no valid private key found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %s", block.Type)
+	}
+}
+
+// checkKeyReusePolicy refuses key reuse when the zone policy doesn't allow it.
+func checkKeyReusePolicy(cl endpoint.Connector, zone string) error {
+	policy, err := cl.ReadPolicyConfiguration(zone)
+	if err != nil {
+		return fmt.Errorf("error reading policy of zone %s: %s", zone, err)
+	}
+	if !policy.AllowKeyReuse {
+		return fmt.Errorf("reuse_private_key is set but policy of zone %s doesn't allow key reuse", zone)
+	}
+	return nil
+}
+
+func generateED25519PrivateKey() (ed25519.PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
+
+// encodePrivateKey returns the PEM form of the private key in the requested format.
+// The pkcs1 format falls back to PKCS#8 for keys which have no PKCS#1 or SEC 1 form.
+func encodePrivateKey(privateKey interface{}, password string, format string) (string, error) {
+	var (
+		block *pem.Block
+		err   error
+	)
+	if _, ok := privateKey.(ed25519.PrivateKey); ok && format == privateKeyFormatPKCS1 {
+		if password != "" {
+			format = privateKeyFormatPKCS8Encrypted
+		} else {
+			format = privateKeyFormatPKCS8
+		}
+	}
+	switch format {
+	case privateKeyFormatPKCS1, "":
+		if password != "" {
+			block, err = certificate.GetEncryptedPrivateKeyPEMBock(privateKey, []byte(password))
+		} else {
+			block, err = certificate.GetPrivateKeyPEMBock(privateKey)
+		}
+		if err != nil {
+			return "", err
+		}
+	case privateKeyFormatPKCS8:
+		if password != "" {
+			return "", fmt.Errorf("key_password can't be used with %s format, use %s", privateKeyFormatPKCS8, privateKeyFormatPKCS8Encrypted)
+		}
+		b, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return "", err
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: b}
+	case privateKeyFormatPKCS8Encrypted:
+		if password == "" {
+			return "", fmt.Errorf("key_password is required for %s format", privateKeyFormatPKCS8Encrypted)
+		}
+		b, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return "", err
+		}
+		b, err = encryptPKCS8PrivateKey(b, []byte(password))
+		if err != nil {
+			return "", err
+		}
+		block = &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: b}
+	default:
+		return "", fmt.Errorf("unknown private key format %s", format)
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
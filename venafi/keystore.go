@@ -0,0 +1,256 @@
+package venafi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	privateKeyStorageState     = "state"
+	privateKeyStorageFile      = "file"
+	privateKeyStorageEncrypted = "encrypted"
+
+	envelopePEMType          = "VENAFI ENCRYPTED PRIVATE KEY"
+	envelopeAlgorithmRSAOAEP = "RSA-OAEP-SHA256+A256GCM"
+	envelopeAlgorithmX25519  = "X25519-HKDF-SHA256+A256GCM"
+
+	privateKeyFileMode = 0600
+)
+
+var oidX25519 = asn1.ObjectIdentifier{1, 3, 101, 110}
+
+// x25519PublicKey is a raw Curve25519 public key taken from a SubjectPublicKeyInfo.
+type x25519PublicKey []byte
+
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+func validatePrivateKeyStorage(v interface{}, k string) (ws []string, errs []error) {
+	switch v.(string) {
+	case privateKeyStorageState, privateKeyStorageFile, privateKeyStorageEncrypted:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of %s, %s or %s, got %s", k, privateKeyStorageState, privateKeyStorageFile, privateKeyStorageEncrypted, v))
+	}
+	return
+}
+
+// parseKeyRecipient reads a PEM encoded RSA or X25519 public key used to encrypt private keys stored in state.
+func parseKeyRecipient(recipientPEM string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(recipientPEM))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("private key recipient must be a PEM encoded PUBLIC KEY")
+	}
+	var spki subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(block.Bytes, &spki); err != nil {
+		return nil, fmt.Errorf("failed to parse private key recipient: %s", err)
+	}
+	if spki.Algorithm.Algorithm.Equal(oidX25519) {
+		if len(spki.PublicKey.Bytes) != 32 {
+			return nil, fmt.Errorf("invalid X25519 public key length %d", len(spki.PublicKey.Bytes))
+		}
+		return x25519PublicKey(spki.PublicKey.Bytes), nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key recipient: %s", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("private key recipient must be an RSA or X25519 public key")
+	}
+	return rsaPub, nil
+}
+
+// sealPrivateKey encrypts the private key PEM for the recipient with a random AES-256-GCM key.
+// The AES key is wrapped with RSA-OAEP or derived from an ephemeral X25519 exchange.
+func sealPrivateKey(keyPEM string, recipient interface{}) (string, error) {
+	headers := map[string]string{}
+	var contentKey []byte
+	switch r := recipient.(type) {
+	case *rsa.PublicKey:
+		contentKey = make([]byte, 32)
+		if _, err := rand.Read(contentKey); err != nil {
+			return "", err
+		}
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, r, contentKey, nil)
+		if err != nil {
+			return "", err
+		}
+		headers["Algorithm"] = envelopeAlgorithmRSAOAEP
+		headers["Encrypted-Key"] = base64.StdEncoding.EncodeToString(wrapped)
+	case x25519PublicKey:
+		var ephemeralPrivate, ephemeralPublic, shared, peer [32]byte
+		if _, err := rand.Read(ephemeralPrivate[:]); err != nil {
+			return "", err
+		}
+		copy(peer[:], r)
+		curve25519.ScalarBaseMult(&ephemeralPublic, &ephemeralPrivate)
+		curve25519.ScalarMult(&shared, &ephemeralPrivate, &peer)
+		var err error
+		contentKey, err = deriveEnvelopeKey(shared[:], ephemeralPublic[:], peer[:])
+		if err != nil {
+			return "", err
+		}
+		headers["Algorithm"] = envelopeAlgorithmX25519
+		headers["Ephemeral-Public-Key"] = base64.StdEncoding.EncodeToString(ephemeralPublic[:])
+	default:
+		return "", fmt.Errorf("unsupported private key recipient %T", recipient)
+	}
+
+	gcm, err := newGCM(contentKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	headers["Nonce"] = base64.StdEncoding.EncodeToString(nonce)
+	block := &pem.Block{
+		Type:    envelopePEMType,
+		Headers: headers,
+		Bytes:   gcm.Seal(nil, nonce, []byte(keyPEM), []byte(headers["Algorithm"])),
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// openPrivateKey reverses sealPrivateKey with the recipient's private key.
+func openPrivateKey(envelope string, recipientKey interface{}) (string, error) {
+	block, _ := pem.Decode([]byte(envelope))
+	if block == nil || block.Type != envelopePEMType {
+		return "", fmt.Errorf("not a %s block", envelopePEMType)
+	}
+	var contentKey []byte
+	switch block.Headers["Algorithm"] {
+	case envelopeAlgorithmRSAOAEP:
+		k, ok := recipientKey.(*rsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("RSA private key is required to open %s envelope", envelopeAlgorithmRSAOAEP)
+		}
+		wrapped, err := base64.StdEncoding.DecodeString(block.Headers["Encrypted-Key"])
+		if err != nil {
+			return "", err
+		}
+		contentKey, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, k, wrapped, nil)
+		if err != nil {
+			return "", err
+		}
+	case envelopeAlgorithmX25519:
+		k, ok := recipientKey.([]byte)
+		if !ok || len(k) != 32 {
+			return "", fmt.Errorf("raw X25519 private key is required to open %s envelope", envelopeAlgorithmX25519)
+		}
+		ephemeralPublic, err := base64.StdEncoding.DecodeString(block.Headers["Ephemeral-Public-Key"])
+		if err != nil || len(ephemeralPublic) != 32 {
+			return "", fmt.Errorf("invalid ephemeral public key")
+		}
+		var private, peer, public, shared [32]byte
+		copy(private[:], k)
+		copy(peer[:], ephemeralPublic)
+		curve25519.ScalarBaseMult(&public, &private)
+		curve25519.ScalarMult(&shared, &private, &peer)
+		contentKey, err = deriveEnvelopeKey(shared[:], ephemeralPublic, public[:])
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported envelope algorithm %q", block.Headers["Algorithm"])
+	}
+
+	gcm, err := newGCM(contentKey)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(block.Headers["Nonce"])
+	if err != nil || len(nonce) != gcm.NonceSize() {
+		return "", fmt.Errorf("invalid envelope nonce")
+	}
+	keyPEM, err := gcm.Open(nil, nonce, block.Bytes, []byte(block.Headers["Algorithm"]))
+	if err != nil {
+		return "", err
+	}
+	return string(keyPEM), nil
+}
+
+func deriveEnvelopeKey(shared, ephemeralPublic, recipientPublic []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeralPublic...), recipientPublic...)
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(envelopeAlgorithmX25519)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// publicKeyFingerprint returns upper case hex SHA-256 of the DER encoded SubjectPublicKeyInfo.
+func publicKeyFingerprint(pub interface{}) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return strings.ToUpper(hex.EncodeToString(sum[:])), nil
+}
+
+// writePrivateKeyFile atomically replaces path with the key readable only by the owner.
+func writePrivateKeyFile(path string, keyPEM string) error {
+	return writeFileAtomic(path, []byte(keyPEM), privateKeyFileMode, -1, -1)
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the same directory so readers never see
+// a partially written file. Ownership is changed unless uid and gid are -1.
+func writeFileAtomic(path string, data []byte, mode os.FileMode, uid int, gid int) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if uid != -1 || gid != -1 {
+		if err = tmp.Chown(uid, gid); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
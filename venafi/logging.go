@@ -0,0 +1,192 @@
+package venafi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// logLevels are the values of the provider log_level, messages are tagged with the level in brackets like [DEBUG]
+var logLevels = []string{"error", "warn", "info", "debug", "trace"}
+
+const (
+	logLevelInfo  = 2
+	logLevelDebug = 3
+
+	redacted = "[REDACTED]"
+	//secretMinLength keeps short registered values like "a" from redacting every occurrence in the logs, secret
+	//attributes are redacted by name whatever their length
+	secretMinLength = 4
+
+	logQuotedValue = `"(?:[^"\\]|\\.)*"`
+)
+
+// logSecretAttributes are the attributes of the provider and its resources which hold secrets
+var logSecretAttributes = []string{"tpp_password", "api_key", "key_password", "pkcs12_password"}
+
+var (
+	logPrivateKeyPattern = regexp.MustCompile(`-----BEGIN ([A-Z0-9 ]*PRIVATE KEY)-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`)
+	//logSecretFieldPattern matches JSON fields like "Password": "..." and "APIKey": "..." of WebSDK and Venafi Cloud requests
+	logSecretFieldPattern = regexp.MustCompile(`(?i)("[\w-]*(?:password|passphrase|apikey|api_key|api-key|token|secret)[\w-]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	//logSecretValuePattern matches headers like tppl-api-key: ... and Go structs printed like {Password:...}
+	logSecretValuePattern = regexp.MustCompile(`(?i)\b((?:tppl-api-key|x-venafi-api-key|authorization|\w*password|apikey)\s*[:=]\s*)[^\s,}"]+`)
+	//logSecretAttributePattern matches secret attributes in the diffs and states Terraform logs, like
+	//key_password: "" => "...", "key_password":*terraform.ResourceAttrDiff{Old:"", New:"..."} and key_password = ...
+	logSecretAttributePattern = regexp.MustCompile(`\b((?:` + strings.Join(logSecretAttributes, "|") + `)"?\s*[:=]\s*)` +
+		`(\*terraform\.ResourceAttrDiff\{Old:` + logQuotedValue + `, New:` + logQuotedValue + `|` +
+		logQuotedValue + `(?:\s*=>\s*` + logQuotedValue + `)?|[^\s,}"]+)`)
+)
+
+func validateLogLevel(v interface{}, k string) (ws []string, errs []error) {
+	for _, l := range logLevels {
+		if v.(string) == l {
+			return
+		}
+	}
+	errs = append(errs, fmt.Errorf("%s must be one of %s, got %s", k, strings.Join(logLevels, ", "), v))
+	return
+}
+
+// logFilter is the output of the standard logger, which vcert logs to as well. It drops messages above the
+// configured level and redacts secrets from the others.
+type logFilter struct {
+	sync.Mutex
+	out   io.Writer
+	level int
+	//secrets are registered once for each distinct provider configuration
+	secrets []*logSecrets
+}
+
+// logSecrets are the credentials of a provider configuration and the API tokens obtained with them, which are
+// redacted wherever they appear in the logs
+type logSecrets struct {
+	sync.Mutex
+	//credentials identify the configuration, providers configured again with them share the secrets
+	credentials string
+	values      map[string]bool
+}
+
+// add registers a secret obtained with the credentials, like a Venafi Platform API token
+func (s *logSecrets) add(value string) {
+	if s == nil || len(value) < secretMinLength {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.values[value] = true
+}
+
+// redact replaces the secrets in message
+func (s *logSecrets) redact(message string) string {
+	s.Lock()
+	defer s.Unlock()
+	for secret := range s.values {
+		message = strings.Replace(message, secret, redacted, -1)
+	}
+	return message
+}
+
+var (
+	providerLogLock sync.Mutex
+	providerLog     *logFilter
+)
+
+// setupLogging routes the standard logger through the filter. It's installed again when the output was replaced
+// since, like go-plugin and the acceptance test framework do. An unknown level keeps the current one.
+func setupLogging(level string) {
+	providerLogLock.Lock()
+	defer providerLogLock.Unlock()
+	if providerLog == nil {
+		providerLog = &logFilter{level: logLevelInfo}
+	}
+	//the standard logger holds its lock while writing to the filter, so it's never called with the filter locked
+	if w := log.Writer(); w != io.Writer(providerLog) {
+		providerLog.Lock()
+		providerLog.out = w
+		providerLog.Unlock()
+		log.SetOutput(providerLog)
+	}
+	providerLog.Lock()
+	defer providerLog.Unlock()
+	for i, l := range logLevels {
+		if l == level {
+			providerLog.level = i
+		}
+	}
+}
+
+// logVerbose tells whether vcert should log every request, which only happens at debug level
+func logVerbose() bool {
+	if providerLog == nil {
+		return false
+	}
+	providerLog.Lock()
+	defer providerLog.Unlock()
+	return providerLog.level >= logLevelDebug
+}
+
+// registerSecrets hides the credentials of a provider configuration in all further log messages, like values which
+// vcert logs in other forms than the redaction patterns match. Configuring a provider again with the same credentials
+// returns the secrets registered before, so they don't pile up.
+func registerSecrets(credentials ...string) *logSecrets {
+	key := strings.Join(credentials, "\x00")
+	setupLogging("")
+	providerLog.Lock()
+	defer providerLog.Unlock()
+	for _, secrets := range providerLog.secrets {
+		if secrets.credentials == key {
+			return secrets
+		}
+	}
+	secrets := &logSecrets{credentials: key, values: map[string]bool{}}
+	for _, v := range credentials {
+		secrets.add(v)
+	}
+	providerLog.secrets = append(providerLog.secrets, secrets)
+	return secrets
+}
+
+func (f *logFilter) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+	if messageLevel(p) > f.level {
+		return len(p), nil
+	}
+	_, err := io.WriteString(f.out, f.redact(string(p)))
+	return len(p), err
+}
+
+// redact replaces private keys, secret fields and attributes and registered secrets
+func (f *logFilter) redact(message string) string {
+	message = logPrivateKeyPattern.ReplaceAllString(message, fmt.Sprintf("-----BEGIN $1-----%s-----END $1-----", redacted))
+	message = logSecretFieldPattern.ReplaceAllString(message, fmt.Sprintf(`$1"%s"`, redacted))
+	message = logSecretAttributePattern.ReplaceAllString(message, "${1}"+redacted)
+	message = logSecretValuePattern.ReplaceAllString(message, "${1}"+redacted)
+	for _, secrets := range f.secrets {
+		message = secrets.redact(message)
+	}
+	return message
+}
+
+// messageLevel returns the level of a message tagged like [DEBUG], untagged messages are logged at info level
+func messageLevel(message []byte) int {
+	start := bytes.IndexByte(message, '[')
+	if start < 0 {
+		return logLevelInfo
+	}
+	end := bytes.IndexByte(message[start:], ']')
+	if end < 0 {
+		return logLevelInfo
+	}
+	tag := strings.ToLower(string(message[start+1 : start+end]))
+	for i, l := range logLevels {
+		if l == tag {
+			return i
+		}
+	}
+	return logLevelInfo
+}
@@ -0,0 +1,232 @@
+package venafi
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"unicode/utf16"
+)
+
+// PKCS#12 (RFC 7292) identifiers used by encodePKCS12.
+var (
+	oidDataContentType     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+const (
+	pkcs12MacIterations = 2048
+	pkcs12MacSaltSize   = 16
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+// encodePKCS12 builds a password protected PKCS#12 archive of the private key and certificates.
+// The key is encrypted with PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC) and the archive is protected with
+// HMAC-SHA256, which OpenSSL 1.1.1 and newer, Java 8u301 and newer and Windows Server 2019 and newer can read.
+// The first certificate is the one matching the key and gets friendlyName set to alias.
+func encodePKCS12(privateKey interface{}, certs []*x509.Certificate, password string, alias string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate to encode")
+	}
+	keyID := sha1.Sum(certs[0].Raw)
+	leafAttributes, err := pkcs12BagAttributes(keyID[:], alias)
+	if err != nil {
+		return nil, err
+	}
+
+	var certBags []safeBag
+	for i, cert := range certs {
+		bag, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: cert.Raw})
+		if err != nil {
+			return nil, err
+		}
+		certSafeBag := safeBag{ID: oidCertBag, Value: explicitTag0(bag)}
+		if i == 0 {
+			certSafeBag.Attributes = leafAttributes
+		}
+		certBags = append(certBags, certSafeBag)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := encryptPKCS8PrivateKey(keyDER, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+	keyBags := []safeBag{{ID: oidPKCS8ShroudedKeyBag, Value: explicitTag0(encryptedKey), Attributes: leafAttributes}}
+
+	var authenticatedSafe []contentInfo
+	for _, bags := range [][]safeBag{certBags, keyBags} {
+		info, err := pkcs12DataContentInfo(bags)
+		if err != nil {
+			return nil, err
+		}
+		authenticatedSafe = append(authenticatedSafe, info)
+	}
+	authSafeDER, err := asn1.Marshal(authenticatedSafe)
+	if err != nil {
+		return nil, err
+	}
+	authSafeContent, err := asn1.Marshal(authSafeDER)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, pkcs12MacSaltSize)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, pkcs12KDF(password, salt, pkcs12MacIterations, 3, sha256.Size))
+	mac.Write(authSafeDER)
+
+	return asn1.Marshal(pfxPdu{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidDataContentType, Content: explicitTag0(authSafeContent)},
+		MacData: macData{
+			Mac: digestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    salt,
+			Iterations: pkcs12MacIterations,
+		},
+	})
+}
+
+func pkcs12DataContentInfo(bags []safeBag) (contentInfo, error) {
+	der, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	content, err := asn1.Marshal(der)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidDataContentType, Content: explicitTag0(content)}, nil
+}
+
+func pkcs12BagAttributes(keyID []byte, alias string) ([]pkcs12Attribute, error) {
+	keyIDValue, err := asn1.Marshal(keyID)
+	if err != nil {
+		return nil, err
+	}
+	attributes := []pkcs12Attribute{{ID: oidLocalKeyID, Value: asn1Set(keyIDValue)}}
+	if alias != "" {
+		name, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpString(alias, false)})
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, pkcs12Attribute{ID: oidFriendlyName, Value: asn1Set(name)})
+	}
+	return attributes, nil
+}
+
+// explicitTag0 wraps DER in [0] EXPLICIT, which encoding/asn1 doesn't do for raw values.
+func explicitTag0(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func asn1Set(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der}
+}
+
+// bmpString returns big endian UTF-16 of s, optionally with the terminating zero used for PKCS#12 passwords.
+func bmpString(s string, terminate bool) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(r>>8), byte(r))
+	}
+	if terminate {
+		b = append(b, 0, 0)
+	}
+	return b
+}
+
+// pkcs12KDF derives size bytes of key material with SHA-256 as described in RFC 7292 appendix B.2.
+func pkcs12KDF(password string, salt []byte, iterations int, id byte, size int) []byte {
+	const u, v = sha256.Size, 64
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	input := append(fill(salt), fill(bmpString(password, true))...)
+
+	var result []byte
+	for len(result) < size {
+		a := sha256.Sum256(append(append([]byte{}, d...), input...))
+		for i := 1; i < iterations; i++ {
+			a = sha256.Sum256(a[:])
+		}
+		result = append(result, a[:]...)
+
+		b := fill(a[:u])
+		for j := 0; j < len(input); j += v {
+			//I_j = (I_j + B + 1) mod 2^(v*8)
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(input[j+k]) + int(b[k]) + carry
+				input[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return result[:size]
+}
@@ -0,0 +1,173 @@
+package venafi
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// opensslPKCS12 was created with OpenSSL 3.0 "pkcs12 -export" using password Venafi123
+const opensslPKCS12 = `
+MIIEHAIBAzCCA9IGCSqGSIb3DQEHAaCCA8MEggO/MIIDuzCCAnIGCSqGSIb3DQEHBqCCAmMwggJf
+AgEAMIICWAYJKoZIhvcNAQcBMFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEFDDAcBAi8grzDlxT+
+SAICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEEDETKfJdAlD2uA0Bg8Ofr0SAggHwcn3O
++BACjyG85UwHo51YTeaV7P9lX7MLhmXkKpFGDrflguOr8b8vih/tw/xJMqNeX7eSzLHgl3ALurN6
+lz9B2pCD9TBJ1BRMWsURRiszfYeYRT8kq9kGRY/B3+jp81nDSIHjKWW9P8p43M058qnwCzjtGlXn
+Daz+0RYdz9lsVSNuQUwCcO1kxjNlmL5GacWsLX5KB7fTNnFAgltyWD3m9MQCCQQoEBsVDWBhpNsz
+RJtRxaRQOsoC4lPVPGfgoSxU/N4iTvXSOMQrfXRUH3rjCuL2UlUfgglNX4DPVv4OlVbI4O5j2bYs
+R/B8S1s63JOPoUBAyPmo4NoNA4pyRWrb6JM3EmR92fBRLvtQQ5Z4sitozTDL+V7QcLUDwS4ps4uv
+t63DeL25zFKWhXf1hVrWmZn7ZjS7qSrHLeHs4RTdO0OgUhqxP6ffWsspNX61FKsbxY4KPo+2gARG
+GmEHCZ6CWzojy3cHl+lK+rT3Y4Gf3QOTfgk/jJEl4KDWoIU0Olf8VJ4OSjyHfcCd4QyWbVE2aMLp
+RYSKnS+YoxjGIuvWpz1RRNmww0LQi0qZZ8CPr0MJKt/u8Ri8ys/o0+081N74L2ys8UPaANUqd2pz
+pczCrFv1sTSuYYD1NNhvYli0+j6NkB7hPANpBwH3uYkwkrJG+DCCAUEGCSqGSIb3DQEHAaCCATIE
+ggEuMIIBKjCCASYGCyqGSIb3DQEMCgECoIHvMIHsMFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEF
+DDAcBAiUiNR0H/pJHwICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEEAtTskGAH4ZV1AXf
+r9WLLqQEgZDrcCAi7e3RMsvi4Vxb7BQZd9adS4S327ouw2KQNUs448lYZZEYjEIvpBDWnbsxCbzu
+GGyz+Da/UFJfGDnsTzPpp6KwYuAb//US72i496Zw1biuqAuBwLglVWsDt2fKSIgoksCb0EzqH+5h
+H5owOdWA53mgJRVbCBeGTa8Ws0I5U8E74HpUUHXkbNKQ0GuCVlMxJTAjBgkqhkiG9w0BCRUxFgQU
+8frtsuClUFdLzV3SqRFsyO3Cn70wQTAxMA0GCWCGSAFlAwQCAQUABCBmA27ZFZhHRI2qzigG8Ilb
+zprGXadRgQi7Z7Lmllw7nwQIMDtd5lsZRNUCAggA`
+
+// openPKCS12 verifies the MAC of a PKCS#12 archive and returns its SafeContents which are not encrypted
+func openPKCS12(t *testing.T, der []byte, password string) [][]safeBag {
+	var pfx pfxPdu
+	if _, err := asn1.Unmarshal(der, &pfx); err != nil {
+		t.Fatal(err)
+	}
+	var authSafeDER []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafeDER); err != nil {
+		t.Fatal(err)
+	}
+	if !pfx.MacData.Mac.Algorithm.Algorithm.Equal(oidSHA256) {
+		t.Fatalf("unexpected MAC algorithm %s", pfx.MacData.Mac.Algorithm.Algorithm)
+	}
+	mac := hmac.New(sha256.New, pkcs12KDF(password, pfx.MacData.MacSalt, pfx.MacData.Iterations, 3, sha256.Size))
+	mac.Write(authSafeDER)
+	if !hmac.Equal(mac.Sum(nil), pfx.MacData.Mac.Digest) {
+		t.Fatal("PKCS#12 MAC verification failed")
+	}
+
+	var authSafe []contentInfo
+	if _, err := asn1.Unmarshal(authSafeDER, &authSafe); err != nil {
+		t.Fatal(err)
+	}
+	var contents [][]safeBag
+	for _, info := range authSafe {
+		if !info.ContentType.Equal(oidDataContentType) {
+			continue
+		}
+		var data []byte
+		if _, err := asn1.Unmarshal(info.Content.Bytes, &data); err != nil {
+			t.Fatal(err)
+		}
+		var bags []safeBag
+		if _, err := asn1.Unmarshal(data, &bags); err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, bags)
+	}
+	return contents
+}
+
+func TestPKCS12KDF(t *testing.T) {
+	der, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(opensslPKCS12))
+	contents := openPKCS12(t, der, "Venafi123")
+	if len(contents) != 1 || len(contents[0]) != 1 || !contents[0][0].ID.Equal(oidPKCS8ShroudedKeyBag) {
+		t.Fatalf("unexpected safe contents %v", contents)
+	}
+	key, err := decryptPKCS8PrivateKey(contents[0][0].Value.Bytes, []byte("Venafi123"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = x509.ParsePKCS8PrivateKey(key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	certPEM, keyPEM := selfSignedCertificate(t, "p12.venafi.example")
+	caPEM, _ := selfSignedCertificate(t, "ca.venafi.example")
+	certs, err := parseCertificates(certPEM + caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := parsePrivateKey([]byte(keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := "pässwörd"
+	der, err := encodePKCS12(pk, certs, password, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []*x509.Certificate
+	var key interface{}
+	for _, bags := range openPKCS12(t, der, password) {
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if _, err = asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					t.Fatal(err)
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded = append(decoded, cert)
+			case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				b, err := decryptPKCS8PrivateKey(bag.Value.Bytes, []byte(password))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if key, err = x509.ParsePKCS8PrivateKey(b); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if !reflect.DeepEqual(decoded, certs) {
+		t.Fatalf("expected %d certificates, got %d", len(certs), len(decoded))
+	}
+	if !reflect.DeepEqual(key.(*ecdsa.PrivateKey).PublicKey, pk.(*ecdsa.PrivateKey).PublicKey) {
+		t.Fatal("private key doesn't match")
+	}
+	checkOpenSSLPKCS12(t, der, password)
+}
+
+// checkOpenSSLPKCS12 verifies with the openssl command line tool that the archive can be read
+func checkOpenSSLPKCS12(t *testing.T, der []byte, password string) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Log("openssl is not available, skipping PKCS#12 check")
+		return
+	}
+	dir, err := ioutil.TempDir("", "venafi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cert.p12")
+	if err = ioutil.WriteFile(path, der, 0600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(openssl, "pkcs12", "-in", path, "-passin", "pass:"+password, "-nodes").CombinedOutput()
+	if err != nil {
+		t.Fatalf("openssl can't read PKCS#12: %s %s", err, out)
+	}
+	if !bytes.Contains(out, []byte("friendlyName: web")) || !bytes.Contains(out, []byte("BEGIN PRIVATE KEY")) {
+		t.Fatalf("unexpected openssl output %s", out)
+	}
+}
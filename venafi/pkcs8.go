@@ -0,0 +1,189 @@
+package venafi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"hash"
+)
+
+// PKCS#5 v2.0 (RFC 8018) and scrypt (RFC 7914) identifiers used by encrypted PKCS#8 keys.
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+const (
+	pbkdf2Iterations = 100000
+	pbkdf2SaltSize   = 16
+)
+
+type encryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+// encryptPKCS8PrivateKey wraps DER encoded PKCS#8 key into EncryptedPrivateKeyInfo
+// using PBES2 with PBKDF2-HMAC-SHA256 and AES-256-CBC.
+func encryptPKCS8PrivateKey(der []byte, password []byte) ([]byte, error) {
+	salt := make([]byte, pbkdf2SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2.Key(password, salt, pbkdf2Iterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	encrypted := append(append([]byte{}, der...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pbkdf2Iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		EncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData:       encrypted,
+	})
+}
+
+// decryptPKCS8PrivateKey returns DER encoded PKCS#8 key from EncryptedPrivateKeyInfo.
+// PBES2 with PBKDF2 or scrypt and AES-CBC are supported.
+func decryptPKCS8PrivateKey(der []byte, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted private key: %s", err)
+	}
+	if !info.EncryptionAlgorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported private key encryption algorithm %s, only PBES2 is supported", info.EncryptionAlgorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("failed to parse PBES2 parameters: %s", err)
+	}
+
+	var keySize int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keySize = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keySize = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keySize = 32
+	default:
+		return nil, fmt.Errorf("unsupported private key cipher %s", params.EncryptionScheme.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("failed to parse cipher parameters: %s", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid IV length %d", len(iv))
+	}
+
+	key, err := deriveKey(params.KeyDerivationFunc, password, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted private key length")
+	}
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(decrypted) ||
+		!bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("private key decryption failed, password is incorrect")
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+func deriveKey(kdf pkix.AlgorithmIdentifier, password []byte, keySize int) ([]byte, error) {
+	switch {
+	case kdf.Algorithm.Equal(oidPBKDF2):
+		var p pbkdf2Params
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse PBKDF2 parameters: %s", err)
+		}
+		var h func() hash.Hash
+		switch {
+		case len(p.PRF.Algorithm) == 0, p.PRF.Algorithm.Equal(oidHMACWithSHA1):
+			h = sha1.New
+		case p.PRF.Algorithm.Equal(oidHMACWithSHA256):
+			h = sha256.New
+		default:
+			return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", p.PRF.Algorithm)
+		}
+		return pbkdf2.Key(password, p.Salt, p.IterationCount, keySize, h), nil
+	case kdf.Algorithm.Equal(oidScrypt):
+		var p scryptParams
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &p); err != nil {
+			return nil, fmt.Errorf("failed to parse scrypt parameters: %s", err)
+		}
+		return scrypt.Key(password, p.Salt, p.CostParameter, p.BlockSize, p.ParallelizationParameter, keySize)
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function %s", kdf.Algorithm)
+	}
+}
@@ -1,27 +1,35 @@
 package venafi
 
 import (
-	"fmt"
+	"context"
 	"github.com/Venafi/vcert"
 	"github.com/Venafi/vcert/pkg/endpoint"
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 	"log"
+	"strings"
+	"time"
 )
 
 const (
-	messageVenafiPingFailed       = "Failed to ping Venafi endpoint: "
-	messageVenafiPingSucessfull   = "Venafi ping sucessfull"
-	messageVenafiClientInitFailed = "Failed to initialize Venafi client: "
-	messageVenafiConfigFailed     = "Failed to build config for Venafi issuer: "
-	messageUseDevMode             = "Using dev mode to issue certificate"
-	messageUseCloud               = "Using Cloud to issue certificate"
+	messageVenafiPingFailed       = "[ERROR] Failed to ping Venafi endpoint: "
+	messageVenafiPingSucessfull   = "[DEBUG] Venafi ping sucessfull"
+	messageVenafiClientInitFailed = "[ERROR] Failed to initialize Venafi client: "
+	messageUseDevMode             = "[INFO] Using dev mode to issue certificate"
+	messageUseCloud               = "[INFO] Using Cloud to issue certificate"
 )
 
 // Provider returns a terraform.ResourceProvider.
 func Provider() terraform.ResourceProvider {
-	return &schema.Provider{
+	provider := &schema.Provider{
 		Schema: map[string]*schema.Schema{
+			"connector": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("VENAFI_CONNECTOR", nil),
+				Description:  "Backend issuing certificates: tpp for Venafi Platform, cloud for Venafi Cloud or dev for dev mode. Guessed from the credentials when not set.",
+				ValidateFunc: validateConnector,
+			},
 			"url": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -47,14 +55,24 @@ Example for Venafi Cloud: Default`,
 			"tpp_password": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
+				Sensitive:   true,
 				DefaultFunc: schema.EnvDefaultFunc("VENAFI_PASS", nil),
 				Description: `Password for WebSDK user. Example: password`,
 			},
 			"api_key": &schema.Schema{
-				Type:        schema.TypeString,
-				Optional:    true,
-				DefaultFunc: schema.EnvDefaultFunc("VENAFI_API", nil),
-				Description: `API key for Venafi Cloud. Example: 142231b7-cvb0-412e-886b-6aeght0bc93d`,
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"tpp_username", "tpp_password"},
+				DefaultFunc:   schema.EnvDefaultFunc("VENAFI_API", nil),
+				Description:   `API key for Venafi Cloud. Example: 142231b7-cvb0-412e-886b-6aeght0bc93d`,
+			},
+			"log_level": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("VENAFI_LOG_LEVEL", "info"),
+				Description:  "Level of messages logged by the provider: error, warn, info, debug or trace. Requests to Venafi are logged at debug level. Secrets are always redacted.",
+				ValidateFunc: validateLogLevel,
 			},
 			"trust_bundle": &schema.Schema{
 				Type:     schema.TypeString,
@@ -67,39 +85,192 @@ Example:
 				Type:        schema.TypeBool,
 				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("VENAFI_DEVMODE", nil),
-				Description: `When set to true, the resulting certificate will be issued by an ephemeral, no trust CA rather than enrolling using Venafi Cloud or Platform. Useful for development and testing.`,
+				Description: `When set to true, the resulting certificate will be issued by a local, no trust CA rather than enrolling using Venafi Cloud or Platform. Useful for development and testing.`,
 			},
+			"dev_ca_certificate": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: `PEM encoded CA certificate which issues certificates in dev mode. The vcert test mode CA is used when not set.
+Example:
+  dev_ca_certificate = "${file("dev-ca.pem")}"`,
+			},
+			"dev_ca_private_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: `PEM encoded private key of dev_ca_certificate.`,
+			},
+			"dev_validity_hours": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     devDefaultValidityHours,
+				Description: `Validity of certificates issued in dev mode, in hours. Certificates never outlive the dev CA.`,
+			},
+			"dev_store_file": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: `JSON file tracking certificates issued, renewed, revoked and imported in dev mode across Terraform runs.
+They are tracked only in memory when not set.`,
+			},
+			"dev_policy":     devPolicySchema(),
+			"dev_simulation": devSimulationSchema(),
+			"private_key_recipient": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Description: `PEM encoded RSA or X25519 public key. Certificates with private_key_storage = "encrypted" keep their private key in state only encrypted for this key.
+Example:
+  private_key_recipient = "${file("recipient.pub")}"`,
+			},
+		},
+
+		DataSourcesMap: map[string]*schema.Resource{
+			"venafi_zone":         dataSourceVenafiZone(),
+			"venafi_certificate":  dataSourceVenafiCertificate(),
+			"venafi_certificates": dataSourceVenafiCertificates(),
+			"venafi_dev_ca":       dataSourceVenafiDevCA(),
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"venafi_certificate": resourceVenafiCertificate(),
+			"venafi_certificate":              resourceVenafiCertificate(),
+			"venafi_certificate_import":       resourceVenafiCertificateImport(),
+			"venafi_policy":                   resourceVenafiPolicy(),
+			"venafi_ssh_certificate":          resourceVenafiSSHCertificate(),
+			"venafi_csr":                      resourceVenafiCSR(),
+			"venafi_certificate_files":        resourceVenafiCertificateFiles(),
+			"venafi_certificate_installation": resourceVenafiCertificateInstallation(),
 		},
+	}
+	provider.ConfigureFunc = func(d *schema.ResourceData) (interface{}, error) {
+		config, err := providerConfigure(d)
+		if err != nil {
+			return nil, err
+		}
+		//the stop context is canceled when Terraform is interrupted
+		config.(*providerConfig).stopContext = provider.StopContext()
+		return config, nil
+	}
+	return &venafiProvider{Provider: provider}
+}
+
+// providerConfig is passed to resources as meta
+type providerConfig struct {
+	vcert *vcert.Config
+	//keyRecipient is an *rsa.PublicKey or x25519PublicKey, nil when not configured
+	keyRecipient interface{}
+	//dev replaces Venafi Platform and Cloud in dev mode
+	dev *devBackend
+	//stopContext is done when Terraform is interrupted, so long running operations give up
+	stopContext context.Context
+	//secrets are the credentials redacted from the logs
+	secrets *logSecrets
+}
+
+// context returns a context which is done after timeout or when Terraform is interrupted
+func (p *providerConfig) context(timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(p.stop(), timeout)
+}
+
+// stop returns the context which is done when Terraform is interrupted
+func (p *providerConfig) stop() context.Context {
+	if p.stopContext == nil {
+		return context.Background()
+	}
+	return p.stopContext
+}
 
-		ConfigureFunc: providerConfigure,
+// withZone returns the configuration with zone in place of the provider zone, or the configuration itself when
+// zone is empty. Connectors created from it request certificates and read policy from zone.
+func (p *providerConfig) withZone(zone string) *providerConfig {
+	if zone == "" {
+		return p
 	}
+	vcertConfig := *p.vcert
+	vcertConfig.Zone = zone
+	config := *p
+	config.vcert = &vcertConfig
+	return &config
+}
+
+// sleepContext waits for d and returns the context error when ctx is done earlier
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// callContext runs f and returns the context error as soon as ctx is done. The call is abandoned, not cancelled:
+// vcert connectors take no context, so f goes on in the background until its HTTP request returns.
+func callContext(ctx context.Context, f func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newConnector creates a connector for the configured endpoint and checks that it is reachable.
+func (p *providerConfig) newConnector() (endpoint.Connector, error) {
+	if p.vcert.ConnectorType == endpoint.ConnectorTypeFake {
+		return newDevConnector(p.dev), nil
+	}
+	cl, err := vcert.NewClient(p.vcert)
+	if err != nil {
+		log.Printf(messageVenafiClientInitFailed + err.Error())
+		return nil, newDiagnostic(p, "error connecting to Venafi", err)
+	}
+	err = callContext(p.stop(), cl.Ping)
+	if err != nil {
+		log.Printf(messageVenafiPingFailed + err.Error())
+		return nil, newDiagnostic(p, "error pinging Venafi", err)
+	}
+	log.Println(messageVenafiPingSucessfull)
+	return cl, nil
 }
 
 func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 
-	log.Printf("Configuring provider\n")
+	setupLogging(d.Get("log_level").(string))
+	log.Printf("[INFO] Configuring provider\n")
 	apiKey := d.Get("api_key").(string)
 	url := d.Get("url").(string)
 	tppUser := d.Get("tpp_username").(string)
 	tppPassword := d.Get("tpp_password").(string)
+	secrets := registerSecrets(apiKey, tppPassword)
 	zone := d.Get("zone").(string)
-	devMode := d.Get("dev_mode").(bool)
 	trustBundle := d.Get("trust_bundle").(string)
+	keyRecipient := d.Get("private_key_recipient").(string)
 
-	var cfg vcert.Config
+	connector, err := connectorSettings{
+		connector:   d.Get("connector").(string),
+		url:         url,
+		tppUser:     tppUser,
+		tppPassword: tppPassword,
+		apiKey:      apiKey,
+		devMode:     d.Get("dev_mode").(bool),
+	}.resolve()
+	if err != nil {
+		return nil, err
+	}
 
-	if devMode {
+	var cfg vcert.Config
+	switch connector {
+	case connectorDev:
 		log.Print(messageUseDevMode)
 		cfg = vcert.Config{
 			ConnectorType: endpoint.ConnectorTypeFake,
-			LogVerbose:    true,
 		}
-	} else if tppUser != "" && tppPassword != "" {
-		log.Printf("Using Platform with url %s to issue certificate\n", url)
+	case connectorTPP:
+		log.Printf("[INFO] Using Platform with url %s to issue certificate\n", url)
 		cfg = vcert.Config{
 			ConnectorType: endpoint.ConnectorTypeTPP,
 			BaseUrl:       url,
@@ -107,49 +278,50 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 				User:     tppUser,
 				Password: tppPassword,
 			},
-			Zone:       zone,
-			LogVerbose: true,
+			Zone: zone,
 		}
-	} else if apiKey != "" {
-		if url != "" {
-			log.Println(messageUseCloud)
-			cfg = vcert.Config{
-				ConnectorType: endpoint.ConnectorTypeCloud,
-				BaseUrl:       url,
-				Credentials: &endpoint.Authentication{
-					APIKey: apiKey,
-				},
-				Zone:       zone,
-				LogVerbose: true,
-			}
-		} else {
-			log.Println(messageUseCloud)
-			cfg = vcert.Config{
-				ConnectorType: endpoint.ConnectorTypeCloud,
-				Credentials: &endpoint.Authentication{
-					APIKey: apiKey,
-				},
-				Zone:       zone,
-				LogVerbose: true,
-			}
+	case connectorCloud:
+		log.Println(messageUseCloud)
+		cfg = vcert.Config{
+			ConnectorType: endpoint.ConnectorTypeCloud,
+			BaseUrl:       url,
+			Credentials: &endpoint.Authentication{
+				APIKey: apiKey,
+			},
+			Zone: zone,
 		}
-	} else {
-		return nil, fmt.Errorf(messageVenafiConfigFailed)
 	}
 
+	//vcert logs every request at debug level
+	cfg.LogVerbose = logVerbose()
 	if trustBundle != "" {
-		log.Printf("Importing trusted certificate: \n %s", trustBundle)
+		log.Printf("[DEBUG] Importing trust bundle with %d certificates", strings.Count(trustBundle, "-----BEGIN CERTIFICATE-----"))
 		cfg.ConnectionTrust = trustBundle
 	}
-	cl, err := vcert.NewClient(&cfg)
-	if err != nil {
-		log.Printf(messageVenafiClientInitFailed + err.Error())
-		return nil, err
+	config := &providerConfig{vcert: &cfg, secrets: secrets}
+	if connector == connectorDev {
+		ca, err := newDevCA(d.Get("dev_ca_certificate").(string), d.Get("dev_ca_private_key").(string), d.Get("dev_validity_hours").(int))
+		if err != nil {
+			return nil, err
+		}
+		config.dev = &devBackend{
+			ca:         ca,
+			policy:     expandDevPolicy(d.Get("dev_policy").([]interface{})),
+			simulation: expandDevSimulation(d.Get("dev_simulation").([]interface{})),
+			store:      openDevStore(d.Get("dev_store_file").(string)),
+			zone:       zone,
+		}
+	}
+	if keyRecipient != "" {
+		recipient, err := parseKeyRecipient(keyRecipient)
+		if err != nil {
+			return nil, err
+		}
+		config.keyRecipient = recipient
 	}
-	err = cl.Ping()
+	_, err = config.newConnector()
 	if err != nil {
-		log.Printf(messageVenafiPingFailed + err.Error())
 		return nil, err
 	}
-	return &cfg, nil
+	return config, nil
 }
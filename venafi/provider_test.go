@@ -9,18 +9,18 @@ import (
 
 var testAccProvider *schema.Provider
 
-var testProvider *schema.Provider
+var testProvider *venafiProvider
 var testProviders map[string]terraform.ResourceProvider
 
 func init() {
-	testProvider = Provider().(*schema.Provider)
+	testProvider = Provider().(*venafiProvider)
 	testProviders = map[string]terraform.ResourceProvider{
 		"venafi": testProvider,
 	}
 }
 
 func TestProvider(t *testing.T) {
-	if err := Provider().(*schema.Provider).InternalValidate(); err != nil {
+	if err := Provider().(*venafiProvider).Provider.InternalValidate(); err != nil {
 		t.Fatalf("err: %s", err)
 	}
 }
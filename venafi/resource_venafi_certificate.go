@@ -1,27 +1,51 @@
 package venafi
 
 import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/Venafi/vcert/pkg/venafi/tpp"
+	"io/ioutil"
 	"net"
+	"net/url"
+	"os"
 	"time"
 
 	"crypto/x509"
 	"encoding/pem"
-	"github.com/Venafi/vcert"
 	"github.com/Venafi/vcert/pkg/certificate"
 	"github.com/hashicorp/terraform/helper/schema"
 	"log"
 	"strings"
 )
 
+var (
+	certificateRetrieveTimeout  = 180 * time.Second
+	certificateRetrieveInterval = 2 * time.Second
+)
+
 func resourceVenafiCertificate() *schema.Resource {
-	return &schema.Resource{
+	resource := &schema.Resource{
 		Create: resourceVenafiCertificateCreate,
 		Read:   resourceVenafiCertificateRead,
+		Update: resourceVenafiCertificateUpdate,
 		Delete: resourceVenafiCertificateDelete,
 
+		CustomizeDiff: resourceVenafiCertificateCustomizeDiff,
+
+		SchemaVersion: 2,
+		MigrateState:  resourceVenafiCertificateMigrateState,
+
+		//renewal happens on refresh, so read waits for certificates as long as create
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(certificateRetrieveTimeout),
+			Read:   schema.DefaultTimeout(certificateRetrieveTimeout),
+		},
+
 		Schema: map[string]*schema.Schema{
 			"common_name": &schema.Schema{
 				Type:        schema.TypeString,
@@ -29,19 +53,27 @@ func resourceVenafiCertificate() *schema.Resource {
 				Description: "Common name of certificate",
 				ForceNew:    true,
 			},
-			"algorithm": &schema.Schema{
+			"zone": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
 				ForceNew:    true,
-				Default:     "RSA",
-				Description: "Key encryption algorithm. RSA or ECDSA. RSA is default.",
+				Description: "Zone to request the certificate from and whose policy applies to it. Provider zone is used when empty.",
+			},
+			"algorithm": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "RSA",
+				Description:  "Key encryption algorithm. RSA, ECDSA or ED25519. RSA is default.",
+				ValidateFunc: validateKeyAlgorithm,
 			},
 			"rsa_bits": &schema.Schema{
-				Type:        schema.TypeInt,
-				Optional:    true,
-				Description: "Number of bits to use when generating an RSA key",
-				ForceNew:    true,
-				Default:     2048,
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Description:  "Number of bits to use when generating an RSA key",
+				ForceNew:     true,
+				Default:      2048,
+				ValidateFunc: validateRSAKeySize,
 			},
 
 			"ecdsa_curve": &schema.Schema{
@@ -51,27 +83,37 @@ func resourceVenafiCertificate() *schema.Resource {
 				ForceNew:    true,
 				Default:     "P521",
 			},
+			"allow_weak_keys": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+				Description: "Allow RSA keys shorter than 2048 bits and the P224 curve",
+			},
 
 			"san_dns": &schema.Schema{
-				Type:        schema.TypeList,
+				Type:        schema.TypeSet,
 				Optional:    true,
 				ForceNew:    true,
-				Description: "List of DNS names to use as subjects of the certificate",
+				Description: "DNS names to use as subjects of the certificate. Case, order and punycode conversion of unicode names are ignored.",
 				Elem:        &schema.Schema{Type: schema.TypeString},
+				Set:         sanSetFunc(normalizeDNSName),
 			},
 			"san_email": &schema.Schema{
-				Type:        schema.TypeList,
+				Type:        schema.TypeSet,
 				Optional:    true,
 				ForceNew:    true,
-				Description: "List of email addresses to use as subjects of the certificate",
+				Description: "Email addresses to use as subjects of the certificate. Case of the domain and order are ignored.",
 				Elem:        &schema.Schema{Type: schema.TypeString},
+				Set:         sanSetFunc(normalizeEmail),
 			},
 			"san_ip": &schema.Schema{
-				Type:        schema.TypeList,
+				Type:        schema.TypeSet,
 				Optional:    true,
 				ForceNew:    true,
-				Description: "List of IP addresses to use as subjects of the certificate",
+				Description: "IP addresses to use as subjects of the certificate. Order and notation of IPv6 addresses are ignored.",
 				Elem:        &schema.Schema{Type: schema.TypeString},
+				Set:         sanSetFunc(normalizeIP),
 			},
 			"key_password": &schema.Schema{
 				Type:        schema.TypeString,
@@ -80,6 +122,21 @@ func resourceVenafiCertificate() *schema.Resource {
 				Description: "Private key password.",
 				Sensitive:   true,
 			},
+			"private_key_format": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      privateKeyFormatPKCS1,
+				Description:  "Format of private_key_pem: pkcs1, pkcs8 or pkcs8-encrypted. pkcs8-encrypted requires key_password.",
+				ValidateFunc: validatePrivateKeyFormat,
+			},
+			"reuse_private_key": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+				Description: "Build the renewal CSR from the existing private key instead of generating a new one. The zone policy must allow key reuse.",
+			},
 			"expiration_window": &schema.Schema{
 				Type:        schema.TypeInt,
 				Optional:    true,
@@ -88,9 +145,34 @@ func resourceVenafiCertificate() *schema.Resource {
 				ForceNew:    true,
 			},
 			"private_key_pem": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
-				Computed: true,
+				Type:      schema.TypeString,
+				Optional:  true,
+				Computed:  true,
+				Sensitive: true,
+			},
+			"private_key_storage": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      privateKeyStorageState,
+				Description:  "Where to keep the private key: state, file (private_key_file only) or encrypted (encrypted_private_key for the provider private_key_recipient)",
+				ValidateFunc: validatePrivateKeyStorage,
+			},
+			"private_key_file": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Path of the file the private key is written to with 0600 permissions when private_key_storage is file",
+			},
+			"encrypted_private_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Private key PEM encrypted for the provider private_key_recipient when private_key_storage is encrypted",
+			},
+			"private_key_fingerprint": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "SHA-256 fingerprint of the DER encoded public key as upper case hex",
 			},
 			"chain": &schema.Schema{
 				Type:     schema.TypeString,
@@ -101,36 +183,104 @@ func resourceVenafiCertificate() *schema.Resource {
 				Computed: true,
 			},
 			"csr_pem": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
-				Computed: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Description:  "PEM encoded CSR to enroll instead of generating a key, e.g. from venafi_csr. The private key stays with the requester.",
+				ValidateFunc: validateCSRPEM,
 			},
 			"certificate_dn": &schema.Schema{
 				Type:     schema.TypeString,
 				Optional: true,
 				Computed: true,
 			},
+			"revoke_on_destroy": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Revoke the certificate when the resource is destroyed. Not supported by Venafi Cloud.",
+			},
+			"revocation_reason": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "Reason of the revocation on destroy: none, key-compromise, ca-compromise, affiliation-changed, superseded or cessation-of-operation",
+				ValidateFunc: validateRevocationReason,
+			},
 		},
 	}
+	for k, v := range certificateMetadataSchema() {
+		resource.Schema[k] = v
+	}
+	return resource
 }
 
-func resourceVenafiCertificateCreate(d *schema.ResourceData, meta interface{}) error {
-	log.Printf("Creating certificate\n")
-	//venafi := meta.(*VenafiClient)
-	cfg := meta.(*vcert.Config)
-	cl, err := vcert.NewClient(cfg)
-	if err != nil {
-		log.Printf(messageVenafiClientInitFailed + err.Error())
-		return err
+// certificateMetadataSchema describes the computed attributes filled by setCertificateMetadata
+func certificateMetadataSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"serial_number": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Serial number of the certificate in decimal form",
+		},
+		"sha1_fingerprint": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "SHA-1 fingerprint (thumbprint) of the certificate as upper case hex",
+		},
+		"sha256_fingerprint": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "SHA-256 fingerprint of the certificate as upper case hex",
+		},
+		"not_before": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Start of the certificate validity period in RFC3339 format",
+		},
+		"not_after": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "End of the certificate validity period in RFC3339 format",
+		},
+		"issuer_dn": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Distinguished name of the certificate issuer",
+		},
+		"subject_dn": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Distinguished name of the certificate subject",
+		},
+		"key_usage": &schema.Schema{
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "List of key usages set in the certificate",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"ext_key_usage": &schema.Schema{
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "List of extended key usages set in the certificate",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"public_key_pem": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Public key of the certificate in PEM format",
+		},
 	}
-	err = cl.Ping()
+}
+
+func resourceVenafiCertificateCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Printf("[INFO] Creating certificate\n")
+	config := meta.(*providerConfig).withZone(d.Get("zone").(string))
+	cl, err := config.newConnector()
 	if err != nil {
-		log.Printf(messageVenafiPingFailed + err.Error())
 		return err
 	}
-	log.Println(messageVenafiPingSucessfull)
 
-	err = enrollVenafiCertificate(d, cl)
+	err = enrollVenafiCertificate(d, cl, config, d.Timeout(schema.TimeoutCreate))
 	if err != nil {
 		return err
 	}
@@ -138,27 +288,85 @@ func resourceVenafiCertificateCreate(d *schema.ResourceData, meta interface{}) e
 }
 
 func resourceVenafiCertificateRead(d *schema.ResourceData, meta interface{}) error {
-
+	if config, ok := meta.(*providerConfig); ok && config.vcert.ConnectorType == endpoint.ConnectorTypeCloud && d.Id() == d.Get("certificate_dn").(string) {
+		//the ID is still the request ID when the certificate ID couldn't be looked up on create, see certificateID
+		ctx, cancel := config.context(d.Timeout(schema.TimeoutRead))
+		id, err := cloudCertificateID(ctx, config, d.Id())
+		cancel()
+		if err != nil {
+			log.Printf("[WARN] Keeping request ID %s as ID of the certificate: %s", d.Id(), err)
+		} else {
+			log.Printf("[INFO] Replacing request ID %s with certificate ID %s", d.Id(), id)
+			d.SetId(id)
+		}
+	}
 	if certUntyped, ok := d.GetOk("certificate"); ok {
 		certPEM := certUntyped.(string)
 		block, _ := pem.Decode([]byte(certPEM))
+		if block == nil {
+			return fmt.Errorf("error decoding certificate PEM in state")
+		}
 		cert, err := x509.ParseCertificate(block.Bytes)
 		if err != nil {
 			return fmt.Errorf("error parsing cert: %s", err)
 		}
 		//Checking Private Key
 		var pk []byte
-		if pkUntyped, ok := d.GetOk("private_key_pem"); ok {
-			pk, err = getPrivateKey([]byte(pkUntyped.(string)), d.Get("key_password").(string))
+		switch d.Get("private_key_storage").(string) {
+		case privateKeyStorageFile:
+			path := d.Get("private_key_file").(string)
+			keyPEM, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				//dropping the resource would orphan the certificate in Venafi, so recreating it is left to the user
+				return &diagnostic{
+					summary:   fmt.Sprintf("private key file %s of the certificate is missing", path),
+					attribute: "private_key_file",
+					hint:      fmt.Sprintf("restore the file, or run terraform taint on the resource to request a new certificate for %s", d.Get("common_name")),
+				}
+			} else if err != nil {
+				return fmt.Errorf("error reading private key file %s: %s", path, err)
+			}
+			pk, err = getPrivateKey(keyPEM, d.Get("key_password").(string))
 			if err != nil {
-				return fmt.Errorf("error getting key: %s", err)
+				return &diagnostic{
+					summary:   fmt.Sprintf("error reading private key from %s", path),
+					err:       err,
+					attribute: "key_password",
+					hint:      "check that key_password decrypts the private key file",
+				}
+			}
+		case privateKeyStorageEncrypted:
+			//the provider only has the recipient public key, so the key pair can't be verified
+		default:
+			if pkUntyped, ok := d.GetOk("private_key_pem"); ok {
+				pk, err = getPrivateKey([]byte(pkUntyped.(string)), d.Get("key_password").(string))
+				if err != nil {
+					return &diagnostic{
+						summary:   "error reading private_key_pem",
+						err:       err,
+						attribute: "key_password",
+						hint:      "check that key_password is the password the private key was encrypted with",
+					}
+				}
+			} else if d.Get("csr_pem").(string) == "" {
+				//certificates enrolled from csr_pem have no private key to verify
+				return &diagnostic{
+					summary:   "private key of the certificate is missing in state",
+					attribute: "private_key_pem",
+					hint:      fmt.Sprintf("run terraform taint on the resource to request a new certificate for %s", d.Get("common_name")),
+				}
+			}
+		}
+		if pk != nil {
+			_, err = tls.X509KeyPair([]byte(certPEM), pk)
+			if err != nil {
+				return fmt.Errorf("error comparing certificate and key: %s", err)
 			}
-		} else {
-			return fmt.Errorf("error getting key")
 		}
-		_, err = tls.X509KeyPair([]byte(certPEM), pk)
+
+		err = setCertificateMetadata(d, cert)
 		if err != nil {
-			return fmt.Errorf("error comparing certificate and key: %s", err)
+			return err
 		}
 
 		//TODO: maybe this check should be up on CSR creation
@@ -168,21 +376,14 @@ func resourceVenafiCertificateRead(d *schema.ResourceData, meta interface{}) err
 		}
 		if renewRequired {
 			//TODO: get request id from resource id
-			log.Printf("Certificate expire %s and should be renewed becouse it`s less than %d hours at this date. Requesting", cert.NotAfter, d.Get("expiration_window").(int))
-			cfg := meta.(*vcert.Config)
-			cl, err := vcert.NewClient(cfg)
-			if err != nil {
-				log.Printf(messageVenafiClientInitFailed + err.Error())
-				return err
-			}
-			err = cl.Ping()
+			log.Printf("[INFO] Certificate expire %s and should be renewed becouse it`s less than %d hours at this date. Requesting", cert.NotAfter, d.Get("expiration_window").(int))
+			config := meta.(*providerConfig).withZone(d.Get("zone").(string))
+			cl, err := config.newConnector()
 			if err != nil {
-				log.Printf(messageVenafiPingFailed + err.Error())
 				return err
 			}
-			log.Println(messageVenafiPingSucessfull)
 
-			err = enrollVenafiCertificate(d, cl)
+			err = enrollVenafiCertificate(d, cl, config, d.Timeout(schema.TimeoutRead))
 			if err != nil {
 				return err
 			}
@@ -192,6 +393,70 @@ func resourceVenafiCertificateRead(d *schema.ResourceData, meta interface{}) err
 	return nil
 }
 
+// resourceVenafiCertificateUpdate only saves revoke_on_destroy and revocation_reason, other attributes force a new certificate
+func resourceVenafiCertificateUpdate(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+func resourceVenafiCertificateCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if config, ok := meta.(*providerConfig); ok && d.Get("revoke_on_destroy").(bool) && config.vcert.ConnectorType == endpoint.ConnectorTypeCloud {
+		return fmt.Errorf("revoke_on_destroy is not supported by Venafi Cloud")
+	}
+	if keyChanged(d, "algorithm", "rsa_bits", "ecdsa_curve", "allow_weak_keys") {
+		err := checkKeyStrength(d.Get("algorithm").(string), d.Get("rsa_bits").(int), d.Get("ecdsa_curve").(string), d.Get("allow_weak_keys").(bool))
+		if err != nil {
+			return err
+		}
+	}
+	if keyChanged(d, "private_key_format", "key_password") {
+		err := checkPrivateKeyFormat(d.Get("private_key_format").(string), d.Get("key_password").(string))
+		if err != nil {
+			return err
+		}
+	}
+	switch d.Get("private_key_storage").(string) {
+	case privateKeyStorageFile:
+		if d.Get("private_key_file").(string) == "" {
+			return fmt.Errorf("private_key_file is required when private_key_storage is %s", privateKeyStorageFile)
+		}
+	case privateKeyStorageEncrypted:
+		if config, ok := meta.(*providerConfig); ok && config.keyRecipient == nil {
+			return fmt.Errorf("private_key_storage is %s but private_key_recipient is not set on the provider", privateKeyStorageEncrypted)
+		}
+		if d.Get("reuse_private_key").(bool) {
+			return fmt.Errorf("reuse_private_key can't be used with private_key_storage %s because the provider can't decrypt the key", privateKeyStorageEncrypted)
+		}
+	}
+	if d.Get("csr_pem").(string) != "" {
+		if d.Get("reuse_private_key").(bool) || d.Get("private_key_storage").(string) != privateKeyStorageState {
+			return fmt.Errorf("csr_pem can't be used with reuse_private_key or private_key_storage because the provider has no private key")
+		}
+	}
+	return nil
+}
+
+// currentPrivateKey returns the private key of the issued certificate for renewal with reuse_private_key.
+func currentPrivateKey(d *schema.ResourceData) (interface{}, error) {
+	var keyPEM []byte
+	switch d.Get("private_key_storage").(string) {
+	case privateKeyStorageFile:
+		var err error
+		keyPEM, err = ioutil.ReadFile(d.Get("private_key_file").(string))
+		if err != nil {
+			return nil, fmt.Errorf("error reading private key file: %s", err)
+		}
+	case privateKeyStorageEncrypted:
+		return nil, fmt.Errorf("private key stored with %s storage can't be reused", privateKeyStorageEncrypted)
+	default:
+		keyPEM = []byte(d.Get("private_key_pem").(string))
+	}
+	keyPEM, err := getPrivateKey(keyPEM, d.Get("key_password").(string))
+	if err != nil {
+		return nil, fmt.Errorf("error getting key: %s", err)
+	}
+	return parsePrivateKey(keyPEM)
+}
+
 func checkForRenew(cert x509.Certificate, expirationWindow int) (renewRequired bool, err error) {
 	renewWindow := time.Duration(expirationWindow) * time.Hour
 	if cert.NotAfter.Sub(cert.NotBefore) < renewWindow {
@@ -202,12 +467,68 @@ func checkForRenew(cert x509.Certificate, expirationWindow int) (renewRequired b
 	return
 }
 
+// setCertificateMetadata fills computed attributes describing the issued certificate
+func setCertificateMetadata(d *schema.ResourceData, cert *x509.Certificate) error {
+	publicKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return fmt.Errorf("error marshaling certificate public key: %s", err)
+	}
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+
+	attributes := map[string]interface{}{
+		"serial_number":      cert.SerialNumber.String(),
+		"sha1_fingerprint":   strings.ToUpper(hex.EncodeToString(sha1Sum[:])),
+		"sha256_fingerprint": strings.ToUpper(hex.EncodeToString(sha256Sum[:])),
+		"not_before":         cert.NotBefore.UTC().Format(time.RFC3339),
+		"not_after":          cert.NotAfter.UTC().Format(time.RFC3339),
+		"issuer_dn":          cert.Issuer.String(),
+		"subject_dn":         cert.Subject.String(),
+		"key_usage":          keyUsageNames(cert.KeyUsage),
+		"ext_key_usage":      extKeyUsageNames(cert.ExtKeyUsage),
+		"public_key_pem":     string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})),
+	}
+	for k, v := range attributes {
+		if err = d.Set(k, v); err != nil {
+			return fmt.Errorf("error setting %s: %s", k, err)
+		}
+	}
+	return nil
+}
+
 func resourceVenafiCertificateDelete(d *schema.ResourceData, meta interface{}) error {
+	if d.Get("revoke_on_destroy").(bool) {
+		config := meta.(*providerConfig).withZone(d.Get("zone").(string))
+		cl, err := config.newConnector()
+		if err != nil {
+			return err
+		}
+		log.Printf("[INFO] Revoking certificate %s", d.Get("certificate_dn"))
+		err = cl.RevokeCertificate(&certificate.RevocationRequest{
+			CertificateDN: d.Get("certificate_dn").(string),
+			Reason:        d.Get("revocation_reason").(string),
+			Comments:      "revoked on destroy by terraform",
+		})
+		if err != nil {
+			diagnostic := newDiagnostic(config, fmt.Sprintf("error revoking certificate %s", d.Get("certificate_dn")), err)
+			diagnostic.pickupID = d.Get("certificate_dn").(string)
+			return diagnostic
+		}
+	}
 	d.SetId("")
 	return nil
 }
 
-func enrollVenafiCertificate(d *schema.ResourceData, cl endpoint.Connector) error {
+func validateRevocationReason(v interface{}, k string) (ws []string, errs []error) {
+	if _, ok := tpp.RevocationReasonsMap[v.(string)]; !ok {
+		errs = append(errs, fmt.Errorf("%s must be one of none, key-compromise, ca-compromise, affiliation-changed, superseded or cessation-of-operation, got %s", k, v))
+	}
+	return
+}
+
+func enrollVenafiCertificate(d *schema.ResourceData, cl endpoint.Connector, config *providerConfig, timeout time.Duration) error {
+	ctx, cancel := config.context(timeout)
+	defer cancel()
 
 	req := &certificate.Request{
 		CsrOrigin: certificate.LocalGeneratedCSR,
@@ -226,10 +547,13 @@ func enrollVenafiCertificate(d *schema.ResourceData, cl endpoint.Connector) erro
 		req.KeyPassword = keyPassword
 	}
 
-	if keyType == "RSA" || len(keyType) == 0 {
+	if keyType == algorithmRSA || len(keyType) == 0 {
 		req.KeyLength = d.Get("rsa_bits").(int)
 		req.KeyType = certificate.KeyTypeRSA
-	} else if keyType == "ECDSA" {
+	} else if keyType == algorithmED25519 {
+		//vcert has no key type for Ed25519 so the CSR is built by the provider
+		req.CsrOrigin = certificate.UserProvidedCSR
+	} else if keyType == algorithmECDSA {
 		keyCurve := d.Get("ecdsa_curve").(string)
 		req.KeyType = certificate.KeyTypeECDSA
 		switch {
@@ -252,14 +576,9 @@ func enrollVenafiCertificate(d *schema.ResourceData, cl endpoint.Connector) erro
 	//Setting up Subject
 	commonName := d.Get("common_name").(string)
 	//Adding alt names if exists
-	dnsnum := d.Get("san_dns.#").(int)
-	if dnsnum > 0 {
-		for i := 0; i < dnsnum; i++ {
-			key := fmt.Sprintf("san_dns.%d", i)
-			val := d.Get(key).(string)
-			log.Printf("Adding SAN %s.", val)
-			req.DNSNames = append(req.DNSNames, val)
-		}
+	for _, name := range sanValues(d.Get("san_dns").(*schema.Set), normalizeDNSName) {
+		log.Printf("[DEBUG] Adding SAN %s.", name)
+		req.DNSNames = append(req.DNSNames, name)
 	}
 
 	if len(commonName) == 0 && len(req.DNSNames) == 0 {
@@ -268,52 +587,63 @@ func enrollVenafiCertificate(d *schema.ResourceData, cl endpoint.Connector) erro
 	if len(commonName) == 0 && len(req.DNSNames) > 0 {
 		commonName = req.DNSNames[0]
 	}
-	if !sliceContains(req.DNSNames, commonName) {
-		log.Printf("Adding CN %s to SAN %s because it wasn't included.", commonName, req.DNSNames)
-		req.DNSNames = append(req.DNSNames, commonName)
+	//Appending common name to the DNS names if it is not there, compared after normalization so it's not added twice
+	if !sliceContains(req.DNSNames, normalizeDNSName(commonName)) {
+		log.Printf("[DEBUG] Adding CN %s to SAN %s because it wasn't included.", commonName, req.DNSNames)
+		req.DNSNames = append(req.DNSNames, normalizeDNSName(commonName))
 	}
 
 	//Obtain a certificate from the Venafi server
-	log.Printf("Using CN %s and SAN %s", commonName, req.DNSNames)
+	log.Printf("[DEBUG] Using CN %s and SAN %s", commonName, req.DNSNames)
 	req.Subject.CommonName = commonName
 
-	emailnum := d.Get("san_email.#").(int)
-	if emailnum > 0 {
-		for i := 0; i < emailnum; i++ {
-			key := fmt.Sprintf("san_email.%d", i)
-			val := d.Get(key).(string)
-			req.EmailAddresses = append(req.EmailAddresses, val)
-		}
-	}
-	ipnum := d.Get("san_ip.#").(int)
-	if ipnum > 0 {
-		ipList := make([]string, 0, ipnum)
-		for i := 0; i < ipnum; i++ {
-			key := fmt.Sprintf("san_ip.%d", i)
-			val := d.Get(key).(string)
-			ipList = append(ipList, val)
-		}
-		for i := 0; i < len(ipList); i += 1 {
-			ip := net.ParseIP(ipList[i])
-			if ip == nil {
-				return fmt.Errorf("invalid IP address %#v", ipList[i])
-			}
-			req.IPAddresses = append(req.IPAddresses, ip)
+	req.EmailAddresses = sanValues(d.Get("san_email").(*schema.Set), normalizeEmail)
+	for _, address := range sanValues(d.Get("san_ip").(*schema.Set), normalizeIP) {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return fmt.Errorf("invalid IP address %#v", address)
 		}
+		req.IPAddresses = append(req.IPAddresses, ip)
 	}
 
-	//Appending common name to the DNS names if it is not there
-	if !sliceContains(req.DNSNames, commonName) {
-		log.Printf("Adding CN %s to SAN because it wasn't included.", commonName)
-		req.DNSNames = append(req.DNSNames, commonName)
+	log.Printf("[DEBUG] Requested SAN: %s", req.DNSNames)
+
+	//Renewal of existing certificate keeps the key when reuse_private_key is set. The policy is checked on create
+	//too, so a zone which doesn't allow key reuse fails on apply rather than on the first renewal.
+	reuseKey := d.Get("reuse_private_key").(bool) && d.Id() != ""
+	if d.Get("reuse_private_key").(bool) && d.Id() == "" {
+		err = checkKeyReusePolicy(cl, config.vcert.Zone)
+		if err != nil {
+			return err
+		}
 	}
 
-	log.Printf("Requested SAN: %s", req.DNSNames)
+	csrPEM := d.Get("csr_pem").(string)
 
-	switch req.KeyType {
-	case certificate.KeyTypeECDSA:
+	switch {
+	case csrPEM != "":
+		log.Println("[DEBUG] Using provided CSR")
+		err = setProvidedCSR(req, csrPEM)
+	case reuseKey:
+		log.Println("[DEBUG] Reusing existing private key for renewal")
+		err = checkKeyReusePolicy(cl, config.vcert.Zone)
+		if err != nil {
+			return err
+		}
+		var pk interface{}
+		pk, err = currentPrivateKey(d)
+		if err == nil {
+			err = generateUserCSR(req, pk)
+		}
+	case req.CsrOrigin == certificate.UserProvidedCSR:
+		var pk interface{}
+		pk, err = generateED25519PrivateKey()
+		if err == nil {
+			err = generateUserCSR(req, pk)
+		}
+	case req.KeyType == certificate.KeyTypeECDSA:
 		req.PrivateKey, err = certificate.GenerateECDSAPrivateKey(req.KeyCurve)
-	case certificate.KeyTypeRSA:
+	case req.KeyType == certificate.KeyTypeRSA:
 		req.PrivateKey, err = certificate.GenerateRSAPrivateKey(req.KeyLength)
 	default:
 		return fmt.Errorf("Unable to generate certificate request, key type %s is not supported", req.KeyType.String())
@@ -323,56 +653,217 @@ func enrollVenafiCertificate(d *schema.ResourceData, cl endpoint.Connector) erro
 		return fmt.Errorf("error generating key: %s", err)
 	}
 
-	log.Println("Making certificate request")
-	err = cl.GenerateRequest(nil, req)
+	log.Println("[DEBUG] Making certificate request")
+	//the connector reads the configuration of its zone, the resource zone when set
+	err = callContext(ctx, func() error {
+		return cl.GenerateRequest(nil, req)
+	})
 	if err != nil {
-		return err
+		return newDiagnostic(config, "error building certificate request", err)
 	}
 
-	requestID, err := cl.RequestCertificate(req, "")
+	var requestID string
+	renewDN := ""
+	if d.Id() != "" && cl.GetType() != endpoint.ConnectorTypeCloud {
+		//Renewal keeps the certificate object, so it stays revocable and its history is kept
+		renewDN = d.Get("certificate_dn").(string)
+	}
+	err = callContext(ctx, func() (err error) {
+		if renewDN != "" {
+			log.Printf("[INFO] Renewing certificate %s", renewDN)
+			requestID, err = cl.RenewCertificate(&certificate.RenewalRequest{
+				CertificateDN:      renewDN,
+				CertificateRequest: req,
+			})
+		} else {
+			log.Printf("[INFO] Requesting certificate in zone %s", config.vcert.Zone)
+			requestID, err = cl.RequestCertificate(req, config.vcert.Zone)
+		}
+		return
+	})
 	if err != nil {
-		return err
+		diagnostic := newDiagnostic(config, "error requesting certificate", err)
+		if d.Id() != "" {
+			diagnostic.summary = "error renewing certificate"
+			diagnostic.pickupID = d.Get("certificate_dn").(string)
+		}
+		return diagnostic
 	}
 
-	pickupReq := &certificate.Request{
-		PickupID: requestID,
-		//TODO: make timeout configurable
-		Timeout: 180 * time.Second,
-	}
 	err = d.Set("certificate_dn", requestID)
 	if err != nil {
 		return err
 	}
 
 	if cl.GetType() == endpoint.ConnectorTypeTPP {
-		log.Println("Waiting 2 seconds as workaround for VEN-46960")
-		time.Sleep(2 * time.Second)
+		log.Println("[DEBUG] Waiting 2 seconds as workaround for VEN-46960")
+		if err = sleepContext(ctx, 2*time.Second); err != nil {
+			return retrieveError(ctx, config, requestID, timeout, "")
+		}
 	}
 
-	pcc, err := cl.RetrieveCertificate(pickupReq)
+	pcc, err := retrieveCertificate(ctx, config, cl, requestID, timeout)
 	if err != nil {
 		return err
 	}
 
-	if pass, ok := d.GetOk("key_password"); ok {
-		err = pcc.AddPrivateKey(req.PrivateKey, []byte(pass.(string)))
-	} else {
-		err = pcc.AddPrivateKey(req.PrivateKey, []byte(""))
+	if err = d.Set("certificate", pcc.Certificate); err != nil {
+		return fmt.Errorf("Error setting certificate: %s", err)
 	}
+	block, _ := pem.Decode([]byte(pcc.Certificate))
+	if block == nil {
+		return fmt.Errorf("error decoding issued certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
-		return err
+		return fmt.Errorf("error parsing cert: %s", err)
 	}
-	if err = d.Set("certificate", pcc.Certificate); err != nil {
-		return fmt.Errorf("Error setting certificate: %s", err)
+	log.Printf("[INFO] Issued certificate %s with serial %s", cert.Subject, cert.SerialNumber.Text(16))
+	err = setCertificateMetadata(d, cert)
+	if err != nil {
+		return err
 	}
-	log.Println("Certificate set to ", pcc.Certificate)
 
 	if err = d.Set("chain", strings.Join((pcc.Chain), "")); err != nil {
 		return fmt.Errorf("error setting chain: %s", err)
 	}
-	log.Println("Certificate chain set to", pcc.Chain)
+	log.Printf("[DEBUG] Certificate chain has %d certificates", len(pcc.Chain))
+
+	d.SetId(certificateID(ctx, config, requestID, cert))
+	if csrPEM != "" {
+		fingerprint, err := publicKeyFingerprint(cert.PublicKey)
+		if err != nil {
+			return fmt.Errorf("error calculating public key fingerprint: %s", err)
+		}
+		return d.Set("private_key_fingerprint", fingerprint)
+	}
+	keyPEM, err := encodePrivateKey(req.PrivateKey, keyPassword, d.Get("private_key_format").(string))
+	if err != nil {
+		return err
+	}
+	log.Println("[DEBUG] Setting up private key")
+	return storePrivateKey(d, config, keyPEM, cert.PublicKey)
+}
+
+// certificateID returns the ID of the issued certificate: the certificate DN for Venafi Platform, the certificate ID
+// for Venafi Cloud and the serial number of the first certificate issued for the request in dev mode. Renewals keep
+// the ID, except with Venafi Cloud which issues a new certificate.
+func certificateID(ctx context.Context, config *providerConfig, pickupID string, cert *x509.Certificate) string {
+	switch config.vcert.ConnectorType {
+	case endpoint.ConnectorTypeCloud:
+		id, err := cloudCertificateID(ctx, config, pickupID)
+		if err != nil {
+			//the certificate is issued already, so it's kept in state under the request ID rather than lost. The ID
+			//equals certificate_dn then, which tells the next refresh to look the certificate ID up again.
+			log.Printf("[WARN] Using request ID %s as ID of the certificate until the next refresh: %s", pickupID, err)
+			return pickupID
+		}
+		return id
+	case endpoint.ConnectorTypeFake:
+		if serial, _ := devSerial(pickupID); serial != "" {
+			return serial
+		}
+		return cert.SerialNumber.Text(16)
+	}
+	return pickupID
+}
+
+// cloudCertificateID returns the ID of the certificate issued for a Venafi Cloud certificate request
+func cloudCertificateID(ctx context.Context, config *providerConfig, requestID string) (string, error) {
+	c, err := newRestClient(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		CertificateIDs []string `json:"certificateIds"`
+	}
+	err = c.request("GET", "certificaterequests/"+url.PathEscape(requestID), nil, &res)
+	if err != nil {
+		return "", fmt.Errorf("failed to read certificate request %s: %s", requestID, err)
+	}
+	if len(res.CertificateIDs) == 0 {
+		return "", fmt.Errorf("certificate request %s has no certificate", requestID)
+	}
+	return res.CertificateIDs[0], nil
+}
+
+// retrieveCertificate polls the certificate until it's issued or ctx is done, vcert can't be interrupted while it waits itself
+func retrieveCertificate(ctx context.Context, config *providerConfig, cl endpoint.Connector, pickupID string, timeout time.Duration) (*certificate.PEMCollection, error) {
+	var status string
+	for {
+		var pcc *certificate.PEMCollection
+		err := callContext(ctx, func() (err error) {
+			pcc, err = cl.RetrieveCertificate(&certificate.Request{PickupID: pickupID})
+			return
+		})
+		if err == nil {
+			return pcc, nil
+		}
+		if ctx.Err() != nil {
+			return nil, retrieveError(ctx, config, pickupID, timeout, status)
+		}
+		pending, ok := err.(endpoint.ErrCertificatePending)
+		if !ok {
+			diagnostic := newDiagnostic(config, "error retrieving certificate", err)
+			diagnostic.pickupID = pickupID
+			return nil, diagnostic
+		}
+		status = pending.Status
+		log.Printf("[DEBUG] Certificate %s is not issued yet, status: %s", pickupID, status)
+		if err = sleepContext(ctx, certificateRetrieveInterval); err != nil {
+			return nil, retrieveError(ctx, config, pickupID, timeout, status)
+		}
+	}
+}
+
+// retrieveError tells why waiting for the certificate stopped and how to pick it up later
+func retrieveError(ctx context.Context, config *providerConfig, pickupID string, timeout time.Duration, status string) error {
+	if status != "" {
+		status = fmt.Sprintf(", last status: %s", status)
+	}
+	diagnostic := newDiagnostic(config, fmt.Sprintf("interrupted while waiting for certificate with pickup ID %s%s", pickupID, status), nil)
+	diagnostic.pickupID = pickupID
+	diagnostic.hint = "retrieve the certificate later with the venafi_certificate data source and pickup_id"
+	if ctx.Err() == context.DeadlineExceeded {
+		diagnostic.summary = fmt.Sprintf("timed out after %s waiting for certificate with pickup ID %s%s", timeout, pickupID, status)
+		diagnostic.attribute = "timeouts"
+		diagnostic.hint = "increase the create and read timeouts of the resource or " + diagnostic.hint
+	}
+	return diagnostic
+}
 
-	d.SetId(req.PickupID)
-	log.Println("Setting up private key")
-	return d.Set("private_key_pem", pcc.PrivateKey)
+// storePrivateKey saves the key according to private_key_storage, so only the state mode keeps it in plain text in state.
+func storePrivateKey(d *schema.ResourceData, config *providerConfig, keyPEM string, publicKey interface{}) error {
+	fingerprint, err := publicKeyFingerprint(publicKey)
+	if err != nil {
+		return fmt.Errorf("error calculating public key fingerprint: %s", err)
+	}
+	if err = d.Set("private_key_fingerprint", fingerprint); err != nil {
+		return fmt.Errorf("error setting private_key_fingerprint: %s", err)
+	}
+
+	switch d.Get("private_key_storage").(string) {
+	case privateKeyStorageFile:
+		path := d.Get("private_key_file").(string)
+		log.Printf("[INFO] Writing private key to %s", path)
+		err = writePrivateKeyFile(path, keyPEM)
+		if err != nil {
+			return fmt.Errorf("error writing private key file %s: %s", path, err)
+		}
+		return d.Set("private_key_pem", "")
+	case privateKeyStorageEncrypted:
+		if config.keyRecipient == nil {
+			return fmt.Errorf("private_key_storage is %s but private_key_recipient is not set on the provider", privateKeyStorageEncrypted)
+		}
+		envelope, err := sealPrivateKey(keyPEM, config.keyRecipient)
+		if err != nil {
+			return fmt.Errorf("error encrypting private key: %s", err)
+		}
+		if err = d.Set("encrypted_private_key", envelope); err != nil {
+			return fmt.Errorf("error setting encrypted_private_key: %s", err)
+		}
+		return d.Set("private_key_pem", "")
+	default:
+		return d.Set("private_key_pem", keyPEM)
+	}
 }
@@ -0,0 +1,393 @@
+package venafi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"github.com/hashicorp/terraform/helper/schema"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	fileEncodingPEM = "pem"
+	fileEncodingDER = "der"
+)
+
+func resourceVenafiCertificateFiles() *schema.Resource {
+	path := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: description,
+		}
+	}
+	return &schema.Resource{
+		Create: resourceVenafiCertificateFilesWrite,
+		Read:   resourceVenafiCertificateFilesRead,
+		Update: resourceVenafiCertificateFilesWrite,
+		Delete: resourceVenafiCertificateFilesDelete,
+
+		CustomizeDiff: resourceVenafiCertificateFilesCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"certificate": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				Description:  "PEM encoded certificate",
+				ValidateFunc: validateCertificatePEM,
+			},
+			"chain": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM encoded certificate chain",
+			},
+			"private_key_pem": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "PEM encoded private key of the certificate",
+			},
+			"key_password": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Password of private_key_pem, needed for pkcs12_path when the key is encrypted",
+			},
+			"certificate_path": path("File to write the certificate to"),
+			"chain_path":       path("File to write the PEM encoded chain to"),
+			"private_key_path": path("File to write the private key to"),
+			"bundle_path":      path("File to write the PEM encoded certificate followed by the chain to"),
+			"pkcs12_path":      path("File to write a PKCS#12 archive of the key, certificate and chain to"),
+			"encoding": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      fileEncodingPEM,
+				Description:  "Encoding of certificate_path and private_key_path: pem or der",
+				ValidateFunc: validateFileEncoding,
+			},
+			"bundle_include_private_key": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Append the private key to bundle_path",
+			},
+			"pkcs12_password": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Password protecting pkcs12_path",
+			},
+			"pkcs12_alias": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Friendly name of the key in pkcs12_path. Common name of the certificate is used when empty.",
+			},
+			"file_mode": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "0644",
+				Description:  "Permissions of files without the private key as an octal string",
+				ValidateFunc: validateFileMode,
+			},
+			"private_key_file_mode": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "0600",
+				Description:  "Permissions of files containing the private key as an octal string",
+				ValidateFunc: validateFileMode,
+			},
+			"owner": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "User name or ID owning the files",
+			},
+			"group": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Group name or ID owning the files",
+			},
+			"checksums": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "SHA-256 of the written files by path",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func validateFileEncoding(v interface{}, k string) (ws []string, errs []error) {
+	switch v.(string) {
+	case fileEncodingPEM, fileEncodingDER:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be %s or %s, got %s", k, fileEncodingPEM, fileEncodingDER, v))
+	}
+	return
+}
+
+func validateFileMode(v interface{}, k string) (ws []string, errs []error) {
+	if _, err := parseFileMode(v.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %s", k, err))
+	}
+	return
+}
+
+func parseFileMode(mode string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("%s is not an octal file mode like 0644", mode)
+	}
+	return os.FileMode(m), nil
+}
+
+func resourceVenafiCertificateFilesCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	paths := 0
+	for _, k := range []string{"certificate_path", "chain_path", "private_key_path", "bundle_path", "pkcs12_path"} {
+		if d.Get(k).(string) != "" {
+			paths++
+		}
+	}
+	if paths == 0 {
+		return fmt.Errorf("at least one of certificate_path, chain_path, private_key_path, bundle_path or pkcs12_path is required")
+	}
+	//the key usually comes from venafi_certificate and is unknown until it is issued
+	if d.NewValueKnown("private_key_pem") && d.Get("private_key_pem").(string) == "" {
+		switch {
+		case d.Get("private_key_path").(string) != "":
+			return fmt.Errorf("private_key_pem is required for private_key_path")
+		case d.Get("pkcs12_path").(string) != "":
+			return fmt.Errorf("private_key_pem is required for pkcs12_path")
+		case d.Get("bundle_include_private_key").(bool):
+			return fmt.Errorf("private_key_pem is required when bundle_include_private_key is set")
+		}
+	}
+	if d.Get("pkcs12_path").(string) != "" && d.NewValueKnown("pkcs12_password") && d.Get("pkcs12_password").(string) == "" {
+		return fmt.Errorf("pkcs12_password is required for pkcs12_path")
+	}
+	return nil
+}
+
+// certificateFileModes returns the permissions of every configured file.
+func certificateFileModes(d *schema.ResourceData) (map[string]os.FileMode, error) {
+	fileMode, err := parseFileMode(d.Get("file_mode").(string))
+	if err != nil {
+		return nil, err
+	}
+	keyFileMode, err := parseFileMode(d.Get("private_key_file_mode").(string))
+	if err != nil {
+		return nil, err
+	}
+	modes := map[string]os.FileMode{}
+	for _, k := range []string{"certificate_path", "chain_path", "bundle_path"} {
+		if path := d.Get(k).(string); path != "" {
+			modes[path] = fileMode
+		}
+	}
+	for _, k := range []string{"private_key_path", "pkcs12_path"} {
+		if path := d.Get(k).(string); path != "" {
+			modes[path] = keyFileMode
+		}
+	}
+	if path := d.Get("bundle_path").(string); path != "" && d.Get("bundle_include_private_key").(bool) {
+		modes[path] = keyFileMode
+	}
+	return modes, nil
+}
+
+// certificateFiles renders the content of every configured file.
+func certificateFiles(d *schema.ResourceData) (map[string][]byte, error) {
+	der := d.Get("encoding").(string) == fileEncodingDER
+
+	certs, err := parseCertificates(d.Get("certificate").(string))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing certificate: %s", err)
+	}
+	chain, err := parseCertificates(d.Get("chain").(string))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing chain: %s", err)
+	}
+	certPEM := encodeCertificates(certs[:1])
+	chainPEM := encodeCertificates(chain)
+	keyPEM := []byte(d.Get("private_key_pem").(string))
+
+	files := map[string][]byte{}
+	if path := d.Get("certificate_path").(string); path != "" {
+		data := certPEM
+		if der {
+			data = certs[0].Raw
+		}
+		files[path] = data
+	}
+	if path := d.Get("chain_path").(string); path != "" {
+		files[path] = chainPEM
+	}
+	if path := d.Get("private_key_path").(string); path != "" {
+		data := keyPEM
+		if der {
+			block, _ := pem.Decode(keyPEM)
+			if block == nil {
+				return nil, fmt.Errorf("error decoding private key PEM")
+			}
+			if x509.IsEncryptedPEMBlock(block) {
+				return nil, fmt.Errorf("legacy encrypted private key can't be written as %s, use private_key_format %s", fileEncodingDER, privateKeyFormatPKCS8Encrypted)
+			}
+			data = block.Bytes
+		}
+		files[path] = data
+	}
+	if path := d.Get("bundle_path").(string); path != "" {
+		data := append(append([]byte{}, certPEM...), chainPEM...)
+		if d.Get("bundle_include_private_key").(bool) {
+			data = append(data, keyPEM...)
+		}
+		files[path] = data
+	}
+	if path := d.Get("pkcs12_path").(string); path != "" {
+		pk, err := getPrivateKey(keyPEM, d.Get("key_password").(string))
+		if err != nil {
+			return nil, fmt.Errorf("error getting key: %s", err)
+		}
+		key, err := parsePrivateKey(pk)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing key: %s", err)
+		}
+		alias := d.Get("pkcs12_alias").(string)
+		if alias == "" {
+			alias = certs[0].Subject.CommonName
+		}
+		data, err := encodePKCS12(key, append(certs[:1], chain...), d.Get("pkcs12_password").(string), alias)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding PKCS#12: %s", err)
+		}
+		files[path] = data
+	}
+	return files, nil
+}
+
+func parseCertificates(data string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %s", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 && strings.TrimSpace(data) != "" {
+		return nil, fmt.Errorf("no PEM encoded certificate found")
+	}
+	return certs, nil
+}
+
+func encodeCertificates(certs []*x509.Certificate) []byte {
+	var b bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return b.Bytes()
+}
+
+func fileChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func resourceVenafiCertificateFilesWrite(d *schema.ResourceData, meta interface{}) error {
+	files, err := certificateFiles(d)
+	if err != nil {
+		return err
+	}
+	modes, err := certificateFileModes(d)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := lookupFileOwner(d.Get("owner").(string), d.Get("group").(string))
+	if err != nil {
+		return err
+	}
+	checksums := map[string]interface{}{}
+	for path, data := range files {
+		log.Printf("[INFO] Writing %s with mode %04o", path, modes[path])
+		if err = writeFileAtomic(path, data, modes[path], uid, gid); err != nil {
+			return fmt.Errorf("error writing %s: %s", path, err)
+		}
+		checksums[path] = fileChecksum(data)
+	}
+	if err = d.Set("checksums", checksums); err != nil {
+		return fmt.Errorf("error setting checksums: %s", err)
+	}
+	d.SetId(fileChecksum([]byte(d.Get("certificate").(string))))
+	return nil
+}
+
+// resourceVenafiCertificateFilesRead removes the resource from state when a file was changed, removed or
+// got different permissions or ownership, so the next apply writes the files again.
+func resourceVenafiCertificateFilesRead(d *schema.ResourceData, meta interface{}) error {
+	modes, err := certificateFileModes(d)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := lookupFileOwner(d.Get("owner").(string), d.Get("group").(string))
+	if err != nil {
+		return err
+	}
+	checksums := d.Get("checksums").(map[string]interface{})
+	for path, mode := range modes {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			log.Printf("[WARN] File %s is missing, files will be written again", path)
+			d.SetId("")
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading %s: %s", path, err)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %s", path, err)
+		}
+		if checksums[path] != fileChecksum(data) {
+			log.Printf("[WARN] File %s was changed, files will be written again", path)
+			d.SetId("")
+			return nil
+		}
+		if !fileModeMatches(info, mode) {
+			log.Printf("[WARN] File %s has mode %04o instead of %04o, files will be written again", path, info.Mode().Perm(), mode)
+			d.SetId("")
+			return nil
+		}
+		if !fileOwnedBy(info, uid, gid) {
+			log.Printf("[WARN] File %s has different owner, files will be written again", path)
+			d.SetId("")
+			return nil
+		}
+	}
+	return nil
+}
+
+func resourceVenafiCertificateFilesDelete(d *schema.ResourceData, meta interface{}) error {
+	for path := range d.Get("checksums").(map[string]interface{}) {
+		log.Printf("[INFO] Removing %s", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing %s: %s", path, err)
+		}
+	}
+	d.SetId("")
+	return nil
+}
@@ -0,0 +1,168 @@
+package venafi
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const devCertificateFilesResource = `
+provider "venafi" {
+  dev_mode = true
+}
+resource "venafi_certificate" "dev" {
+  common_name = "%s"
+  algorithm = "ECDSA"
+  ecdsa_curve = "P256"
+}
+resource "venafi_certificate_files" "dev" {
+  certificate = "${venafi_certificate.dev.certificate}"
+  chain = "${venafi_certificate.dev.chain}"
+  private_key_pem = "${venafi_certificate.dev.private_key_pem}"
+  certificate_path = "%[2]s/cert.%[3]s"
+  chain_path = "%[2]s/chain.pem"
+  private_key_path = "%[2]s/key.%[3]s"
+  bundle_path = "%[2]s/bundle.pem"
+  bundle_include_private_key = true
+  pkcs12_path = "%[2]s/cert.p12"
+  pkcs12_password = "changeit"
+  encoding = "%[3]s"
+  file_mode = "0640"
+}`
+
+// checkCertificateFile compares the certificate file with the certificate of the resource
+func checkCertificateFile(s *terraform.State, path string, der bool) error {
+	attrs := s.RootModule().Resources["venafi_certificate.dev"].Primary.Attributes
+	block, _ := pem.Decode([]byte(attrs["certificate"]))
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !der {
+		fileBlock, _ := pem.Decode(data)
+		if fileBlock == nil {
+			return fmt.Errorf("%s is not PEM encoded", path)
+		}
+		data = fileBlock.Bytes
+	}
+	if string(data) != string(block.Bytes) {
+		return fmt.Errorf("%s doesn't contain the issued certificate", path)
+	}
+	return nil
+}
+
+func checkFileModes(expected map[string]os.FileMode) error {
+	for path, mode := range expected {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.Mode().Perm() != mode {
+			return fmt.Errorf("%s: expected mode %04o, got %04o", path, mode, info.Mode().Perm())
+		}
+	}
+	return nil
+}
+
+func TestDevCertificateFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "venafi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certPath := filepath.Join(dir, "cert.pem")
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			files, _ := filepath.Glob(filepath.Join(dir, "*"))
+			if len(files) != 0 {
+				return fmt.Errorf("files were not removed: %s", files)
+			}
+			return nil
+		},
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: fmt.Sprintf(devCertificateFilesResource, "files.venafi.example", dir, fileEncodingPEM),
+				Check: func(s *terraform.State) error {
+					if err := checkCertificateFile(s, certPath, false); err != nil {
+						return err
+					}
+					attrs := s.RootModule().Resources["venafi_certificate_files.dev"].Primary.Attributes
+					if attrs["checksums.%"] != "5" {
+						return fmt.Errorf("expected 5 checksums, got %s", attrs["checksums.%"])
+					}
+					p12, err := ioutil.ReadFile(filepath.Join(dir, "cert.p12"))
+					if err != nil {
+						return err
+					}
+					openPKCS12(t, p12, "changeit")
+					return checkFileModes(map[string]os.FileMode{
+						certPath:                         0640,
+						filepath.Join(dir, "chain.pem"):  0640,
+						filepath.Join(dir, "key.pem"):    0600,
+						filepath.Join(dir, "bundle.pem"): 0600,
+						filepath.Join(dir, "cert.p12"):   0600,
+					})
+				},
+			},
+			r.TestStep{
+				//changed and missing files are written again
+				PreConfig: func() {
+					ioutil.WriteFile(certPath, []byte("changed"), 0640)
+					os.Remove(filepath.Join(dir, "chain.pem"))
+				},
+				Config: fmt.Sprintf(devCertificateFilesResource, "files.venafi.example", dir, fileEncodingPEM),
+				Check: func(s *terraform.State) error {
+					if _, err := os.Stat(filepath.Join(dir, "chain.pem")); err != nil {
+						return err
+					}
+					return checkCertificateFile(s, certPath, false)
+				},
+			},
+			r.TestStep{
+				//permission drift is fixed too
+				PreConfig: func() {
+					os.Chmod(filepath.Join(dir, "key.pem"), 0644)
+				},
+				Config: fmt.Sprintf(devCertificateFilesResource, "files.venafi.example", dir, fileEncodingPEM),
+				Check: func(s *terraform.State) error {
+					return checkFileModes(map[string]os.FileMode{filepath.Join(dir, "key.pem"): 0600})
+				},
+			},
+			r.TestStep{
+				//a new certificate is written in place of the old one
+				Config: fmt.Sprintf(devCertificateFilesResource, "files2.venafi.example", dir, fileEncodingDER),
+				Check: func(s *terraform.State) error {
+					if err := checkCertificateFile(s, filepath.Join(dir, "cert.der"), true); err != nil {
+						return err
+					}
+					data, err := ioutil.ReadFile(filepath.Join(dir, "key.der"))
+					if err != nil {
+						return err
+					}
+					if _, err = x509.ParseECPrivateKey(data); err != nil {
+						return fmt.Errorf("key.der is not a DER encoded EC key: %s", err)
+					}
+					if _, err = os.Stat(certPath); !os.IsNotExist(err) {
+						return fmt.Errorf("%s should be removed when the path changes", certPath)
+					}
+					return nil
+				},
+			},
+			r.TestStep{
+				//renewed certificate is written to the same files
+				Config: fmt.Sprintf(devCertificateFilesResource, "files3.venafi.example", dir, fileEncodingDER),
+				Check: func(s *terraform.State) error {
+					return checkCertificateFile(s, filepath.Join(dir, "cert.der"), true)
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,228 @@
+package venafi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/certificate"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"log"
+)
+
+func resourceVenafiCertificateImport() *schema.Resource {
+	resource := &schema.Resource{
+		Create: resourceVenafiCertificateImportCreate,
+		Read:   resourceVenafiCertificateImportRead,
+		Delete: resourceVenafiCertificateImportDelete,
+
+		Schema: map[string]*schema.Schema{
+			"policy_dn": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Policy folder to import the certificate into. Provider zone is used when empty.",
+			},
+			"object_name": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Name of the certificate object. Venafi Platform uses the common name when empty.",
+			},
+			"certificate": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				Description:  "PEM encoded certificate to import",
+				ValidateFunc: validateCertificatePEM,
+			},
+			"private_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Description: "PEM encoded private key of the certificate",
+			},
+			"key_password": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Description: "Password of the private key",
+			},
+			"reconcile": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+				Description: "Whether Venafi Platform should reconcile the certificate with an existing object of the same name",
+			},
+			"ca_specific_attributes": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "CA specific attributes to set on the certificate object",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"certificate_dn": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "DN of the imported certificate object",
+			},
+			"guid": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "GUID of the imported certificate object",
+			},
+			"certificate_vault_id": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Secret store vault ID of the certificate",
+			},
+			"private_key_vault_id": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Secret store vault ID of the private key",
+			},
+		},
+	}
+	for k, v := range certificateMetadataSchema() {
+		resource.Schema[k] = v
+	}
+	return resource
+}
+
+func validateCertificatePEM(v interface{}, k string) (ws []string, errs []error) {
+	block, _ := pem.Decode([]byte(v.(string)))
+	if block == nil || block.Type != "CERTIFICATE" {
+		errs = append(errs, fmt.Errorf("%s must be a PEM encoded CERTIFICATE", k))
+		return
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		errs = append(errs, fmt.Errorf("error parsing %s: %s", k, err))
+	}
+	return
+}
+
+func resourceVenafiCertificateImportCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	certPEM := d.Get("certificate").(string)
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return fmt.Errorf("error decoding certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing cert: %s", err)
+	}
+
+	req := &certificate.ImportRequest{
+		PolicyDN:        d.Get("policy_dn").(string),
+		ObjectName:      d.Get("object_name").(string),
+		CertificateData: certPEM,
+		Reconcile:       d.Get("reconcile").(bool),
+	}
+	if req.PolicyDN != "" {
+		req.PolicyDN = tppPolicyDN(req.PolicyDN)
+	}
+	if keyPEM := d.Get("private_key").(string); keyPEM != "" {
+		pk, err := getPrivateKey([]byte(keyPEM), d.Get("key_password").(string))
+		if err != nil {
+			return fmt.Errorf("error getting key: %s", err)
+		}
+		_, err = tls.X509KeyPair([]byte(certPEM), pk)
+		if err != nil {
+			return fmt.Errorf("error comparing certificate and key: %s", err)
+		}
+		req.PrivateKeyData = keyPEM
+		req.Password = d.Get("key_password").(string)
+	}
+	if attributes := d.Get("ca_specific_attributes").(map[string]interface{}); len(attributes) > 0 {
+		req.CASpecificAttributes = make(map[string]string, len(attributes))
+		for k, v := range attributes {
+			req.CASpecificAttributes[k] = v.(string)
+		}
+	}
+
+	cl, err := config.newConnector()
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Importing certificate %s into %s", cert.Subject.CommonName, req.PolicyDN)
+	res, err := cl.ImportCertificate(req)
+	if err != nil {
+		diagnostic := newDiagnostic(config, "error importing certificate", err)
+		if req.PolicyDN != "" {
+			diagnostic.zone = req.PolicyDN
+		}
+		return diagnostic
+	}
+
+	attributes := map[string]interface{}{
+		"certificate_dn":       res.CertificateDN,
+		"guid":                 res.Guid,
+		"certificate_vault_id": res.CertificateVaultId,
+		"private_key_vault_id": res.PrivateKeyVaultId,
+	}
+	for k, v := range attributes {
+		if err = d.Set(k, v); err != nil {
+			return fmt.Errorf("error setting %s: %s", k, err)
+		}
+	}
+	err = setCertificateMetadata(d, cert)
+	if err != nil {
+		return err
+	}
+	d.SetId(res.CertificateDN)
+	return nil
+}
+
+// resourceVenafiCertificateImportRead removes the certificate from state when its object was deleted
+func resourceVenafiCertificateImportRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	dn := d.Get("certificate_dn").(string)
+	switch config.vcert.ConnectorType {
+	case endpoint.ConnectorTypeFake:
+		exists, err := config.dev.imported(dn, d.Get("certificate").(string))
+		if err != nil {
+			return newDiagnostic(config, "error reading imported certificate", err)
+		}
+		if !exists {
+			log.Printf("[WARN] Imported certificate %s doesn't exist anymore", dn)
+			d.SetId("")
+			return nil
+		}
+	case endpoint.ConnectorTypeTPP:
+		c, err := newRestClient(config.stopContext, config)
+		if err != nil {
+			return newDiagnostic(config, "error connecting to Venafi", err)
+		}
+		res, err := tppConfig(c, "DnToGuid", map[string]interface{}{"ObjectDN": dn})
+		if res != nil && res.Result == tppConfigResultObjectAbsent {
+			log.Printf("[WARN] Imported certificate %s doesn't exist anymore", dn)
+			d.SetId("")
+			return nil
+		} else if err != nil {
+			return newDiagnostic(config, fmt.Sprintf("error reading imported certificate %s", dn), err)
+		}
+		if err = d.Set("guid", res.GUID); err != nil {
+			return fmt.Errorf("error setting guid: %s", err)
+		}
+	}
+
+	block, _ := pem.Decode([]byte(d.Get("certificate").(string)))
+	if block == nil {
+		return fmt.Errorf("error decoding certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("error parsing cert: %s", err)
+	}
+	return setCertificateMetadata(d, cert)
+}
+
+func resourceVenafiCertificateImportDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
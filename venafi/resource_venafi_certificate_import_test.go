@@ -0,0 +1,205 @@
+package venafi
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/certificate"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// selfSignedCertificate returns PEM encoded certificate and key for import tests
+func selfSignedCertificate(t *testing.T, cn string) (string, string) {
+	pk, err := certificate.GenerateECDSAPrivateKey(certificate.EllipticCurveP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &pk.PublicKey, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := encodePrivateKey(pk, "", privateKeyFormatPKCS8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), keyPEM
+}
+
+func TestTPPCertificateImport(t *testing.T) {
+	certPEM, keyPEM := selfSignedCertificate(t, "import.venafi.example")
+	var (
+		lock     sync.Mutex
+		imported bool
+	)
+	server := newTPPTestServer(t, map[string]http.HandlerFunc{
+		"/vedsdk/Config/DnToGuid": func(w http.ResponseWriter, r *http.Request) {
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			lock.Lock()
+			defer lock.Unlock()
+			if !imported || req["ObjectDN"] != "\\VED\\Policy\\devops\\imported\\partner" {
+				fmt.Fprint(w, `{"Result": 400, "Error": "Object does not exist"}`)
+				return
+			}
+			fmt.Fprint(w, `{"Result": 1, "GUID": "{import-guid}", "ClassName": "X509 Certificate"}`)
+		},
+		"/vedsdk/certificates/import": func(w http.ResponseWriter, r *http.Request) {
+			var req certificate.ImportRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.PolicyDN != "\\VED\\Policy\\devops\\imported" || req.ObjectName != "partner" || !req.Reconcile {
+				t.Errorf("unexpected import request %+v", req)
+			}
+			if req.CertificateData != certPEM || req.PrivateKeyData != keyPEM {
+				t.Error("certificate or private key data doesn't match")
+			}
+			if req.CASpecificAttributes["Origin"] != "ACME" {
+				t.Errorf("unexpected CA specific attributes %v", req.CASpecificAttributes)
+			}
+			lock.Lock()
+			imported = true
+			lock.Unlock()
+			fmt.Fprint(w, `{"CertificateDN": "\\VED\\Policy\\devops\\imported\\partner", "CertificateVaultId": 101, "Guid": "{import-guid}", "PrivateKeyVaultId": 102}`)
+		},
+	})
+	defer server.Close()
+
+	config := tppTestProviderConfig(server) + fmt.Sprintf(`
+resource "venafi_certificate_import" "partner" {
+  policy_dn = "devops\\imported"
+  object_name = "partner"
+  reconcile = true
+  certificate = <<EOF
+%sEOF
+  private_key = <<EOF
+%sEOF
+  ca_specific_attributes = {
+    Origin = "ACME"
+  }
+}`, certPEM, keyPEM)
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+				Check: func(s *terraform.State) error {
+					rs := s.RootModule().Resources["venafi_certificate_import.partner"]
+					expected := map[string]string{
+						"certificate_dn":       "\\VED\\Policy\\devops\\imported\\partner",
+						"guid":                 "{import-guid}",
+						"certificate_vault_id": "101",
+						"private_key_vault_id": "102",
+						"subject_dn":           "CN=import.venafi.example",
+						"serial_number":        "42",
+					}
+					for k, v := range expected {
+						if rs.Primary.Attributes[k] != v {
+							return fmt.Errorf("expected %s to be %s, got %s", k, v, rs.Primary.Attributes[k])
+						}
+					}
+					if rs.Primary.ID != expected["certificate_dn"] {
+						return fmt.Errorf("unexpected ID %s", rs.Primary.ID)
+					}
+					return nil
+				},
+			},
+			r.TestStep{
+				//the certificate is imported again after its object was deleted
+				PreConfig: func() {
+					lock.Lock()
+					defer lock.Unlock()
+					imported = false
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestDevCertificateImport(t *testing.T) {
+	certPEM, _ := selfSignedCertificate(t, "import.venafi.example")
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: fmt.Sprintf(`
+provider "venafi" {
+  dev_mode = true
+}
+resource "venafi_certificate_import" "partner" {
+  object_name = "partner"
+  certificate = <<EOF
+%sEOF
+}
+data "venafi_certificate" "partner" {
+  certificate_dn = "${venafi_certificate_import.partner.certificate_dn}"
+}`, certPEM),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate_import.partner", "certificate_dn", "\\VED\\Policy\\Default\\partner"),
+					r.TestCheckResourceAttr("data.venafi_certificate.partner", "certificate", certPEM),
+					r.TestCheckResourceAttr("data.venafi_certificate.partner", "chain", ""),
+				),
+			},
+		},
+	})
+}
+
+const devImportStoreConfig = `
+provider "venafi" {
+  dev_mode = true
+  dev_store_file = "%s"
+}
+resource "venafi_certificate_import" "partner" {
+  object_name = "partner"
+  certificate = <<EOF
+%sEOF
+}`
+
+func TestDevCertificateImportDeleted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "venafi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "store.json")
+	certPEM, _ := selfSignedCertificate(t, "import.venafi.example")
+	config := fmt.Sprintf(devImportStoreConfig, filepath.ToSlash(path), certPEM)
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+			},
+			r.TestStep{
+				//the certificate is imported again after it was removed from the store
+				PreConfig: func() {
+					if err := ioutil.WriteFile(path, []byte(`{"Certificates": {}}`), 0600); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
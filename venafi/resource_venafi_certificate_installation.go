@@ -0,0 +1,501 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	tppClassDevice = "Device"
+
+	provisioningStatusAssociated  = "associated"
+	provisioningStatusDissociated = "dissociated"
+	provisioningStatusPending     = "pending"
+	provisioningStatusInstalled   = "installed"
+	provisioningStatusFailed      = "failed"
+)
+
+var (
+	provisioningTimeout  = 10 * time.Minute
+	provisioningInterval = 5 * time.Second
+)
+
+func resourceVenafiCertificateInstallation() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceVenafiCertificateInstallationCreate,
+		Read:   resourceVenafiCertificateInstallationRead,
+		Update: resourceVenafiCertificateInstallationUpdate,
+		Delete: resourceVenafiCertificateInstallationDelete,
+
+		CustomizeDiff: resourceVenafiCertificateInstallationCustomizeDiff,
+
+		//provisioning is waited for on create and when the certificate is pushed again
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(provisioningTimeout),
+			Update: schema.DefaultTimeout(provisioningTimeout),
+		},
+
+		Schema: map[string]*schema.Schema{
+			"certificate_dn": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "DN of the certificate to install, e.g. certificate_dn of venafi_certificate",
+			},
+			"device_dn": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"device_name"},
+				Description:   "DN of an existing device the application is created on",
+			},
+			"device_name": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Name of the device which is created in policy_dn unless it already exists",
+			},
+			"device_host": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Host name or address of the created device",
+			},
+			"policy_dn": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "Policy folder of the created device. Folder of the certificate is used when not set.",
+			},
+			"application_name": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the application object which is created on the device unless it already exists",
+			},
+			"application_class": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Class of the application, e.g. Apache, Basic, PKCS#12 or F5 LTM Advanced",
+			},
+			"application_attributes": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Attributes of the created application, e.g. Driver Name and installation paths",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"certificate_thumbprint": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Thumbprint of the installed certificate, the certificate is pushed again when it changes",
+			},
+			"push": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Push the certificate to the application, otherwise it's only associated",
+			},
+			"wait_for_provisioning": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Wait until the push is completed and fail when provisioning fails",
+			},
+			"application_dn": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"certificate_guid": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"provisioning_status": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "associated, pending, installed, failed or dissociated when the association was removed outside of Terraform",
+			},
+			"provisioning_details": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Processing status reported by Venafi Platform",
+			},
+			"last_pushed_on": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"device_created": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Device was created by this resource and is deleted with it",
+			},
+			"application_created": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Application was created by this resource and is deleted with it",
+			},
+		},
+	}
+}
+
+// tppInstallationClient returns WebSDK client, applications exist only on Venafi Platform
+func tppInstallationClient(meta interface{}) (*restClient, error) {
+	config := meta.(*providerConfig)
+	if config.vcert.ConnectorType != endpoint.ConnectorTypeTPP {
+		diagnostic := newDiagnostic(config, "venafi_certificate_installation is supported only by Venafi Platform", nil)
+		diagnostic.hint = "configure the provider with url, tpp_username and tpp_password"
+		return nil, diagnostic
+	}
+	c, err := newRestClient(config.stopContext, config)
+	if err != nil {
+		return nil, newDiagnostic(config, "error connecting to Venafi", err)
+	}
+	return c, nil
+}
+
+type tppCertificateDetails struct {
+	Consumers         []string `json:"Consumers"`
+	ProcessingDetails struct {
+		InError bool   `json:"InError"`
+		Stage   int    `json:"Stage"`
+		Status  string `json:"Status"`
+	} `json:"ProcessingDetails"`
+}
+
+type tppCertificateOperationResponse struct {
+	Success bool   `json:"Success"`
+	Error   string `json:"Error"`
+}
+
+// tppCertificateOperation calls Certificates/Associate, Push or Dissociate
+func tppCertificateOperation(c *restClient, operation string, data map[string]interface{}) error {
+	var res tppCertificateOperationResponse
+	err := c.request("POST", "Certificates/"+operation, data, &res)
+	if err != nil {
+		return err
+	}
+	if !res.Success {
+		return fmt.Errorf("Certificates/%s failed for %s: %s", operation, data["CertificateDN"], res.Error)
+	}
+	return nil
+}
+
+// tppParentDN returns DN of the folder containing the object
+func tppParentDN(dn string) string {
+	if i := strings.LastIndex(dn, "\\"); i > 0 {
+		return dn[:i]
+	}
+	return dn
+}
+
+// tppObjectExists checks the DN with Config/IsValid
+func tppObjectExists(c *restClient, dn string) (bool, error) {
+	res, err := tppConfig(c, "IsValid", map[string]interface{}{"ObjectDN": dn})
+	if err != nil && res != nil && res.Result == tppConfigResultObjectAbsent {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// tppCreateObject creates the object unless it exists and reports whether it was created
+func tppCreateObject(c *restClient, dn string, class string, attributes map[string]string) (bool, error) {
+	exists, err := tppObjectExists(c, dn)
+	if err != nil || exists {
+		return false, err
+	}
+	var list []map[string]string
+	for name, value := range attributes {
+		list = append(list, map[string]string{"Name": name, "Value": value})
+	}
+	log.Printf("[INFO] Creating %s %s", class, dn)
+	_, err = tppConfig(c, "Create", map[string]interface{}{"ObjectDN": dn, "Class": class, "NameAttributeList": list})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func resourceVenafiCertificateInstallationCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	c, err := tppInstallationClient(meta)
+	if err != nil {
+		return err
+	}
+	certDN := d.Get("certificate_dn").(string)
+	res, err := tppConfig(c, "DnToGuid", map[string]interface{}{"ObjectDN": certDN})
+	if err != nil {
+		diagnostic := newDiagnostic(config, "error looking up certificate", err)
+		diagnostic.attribute = "certificate_dn"
+		return diagnostic
+	}
+	d.Set("certificate_guid", res.GUID)
+
+	deviceDN := d.Get("device_dn").(string)
+	if deviceDN != "" {
+		exists, err := tppObjectExists(c, deviceDN)
+		if err != nil {
+			return newDiagnostic(config, fmt.Sprintf("error looking up device %s", deviceDN), err)
+		}
+		if !exists {
+			diagnostic := newDiagnostic(config, fmt.Sprintf("device %s doesn't exist", deviceDN), nil)
+			diagnostic.attribute = "device_dn"
+			diagnostic.hint = "set device_name instead of device_dn to create the device"
+			return diagnostic
+		}
+	} else {
+		name := d.Get("device_name").(string)
+		if name == "" {
+			diagnostic := newDiagnostic(config, "either device_dn or device_name must be set", nil)
+			diagnostic.attribute = "device_name"
+			return diagnostic
+		}
+		policyDN := tppParentDN(certDN)
+		if v := d.Get("policy_dn").(string); v != "" {
+			policyDN = tppPolicyDN(v)
+		}
+		d.Set("policy_dn", policyDN)
+		deviceDN = policyDN + "\\" + name
+		attributes := map[string]string{}
+		if host := d.Get("device_host").(string); host != "" {
+			attributes["Host"] = host
+		}
+		created, err := tppCreateObject(c, deviceDN, tppClassDevice, attributes)
+		if err != nil {
+			diagnostic := newDiagnostic(config, fmt.Sprintf("error creating device %s", deviceDN), err)
+			diagnostic.attribute = "policy_dn"
+			return diagnostic
+		}
+		d.Set("device_created", created)
+		d.Set("device_dn", deviceDN)
+	}
+
+	appDN := deviceDN + "\\" + d.Get("application_name").(string)
+	attributes := map[string]string{}
+	for k, v := range d.Get("application_attributes").(map[string]interface{}) {
+		attributes[k] = v.(string)
+	}
+	created, err := tppCreateObject(c, appDN, d.Get("application_class").(string), attributes)
+	if err != nil {
+		if d.Get("device_created").(bool) {
+			log.Printf("[INFO] Deleting device %s created for the application", deviceDN)
+			if _, err := tppConfig(c, "Delete", map[string]interface{}{"ObjectDN": deviceDN, "Recursive": false}); err != nil {
+				log.Printf("[WARN] Device %s was kept: %s", deviceDN, err)
+			}
+		}
+		diagnostic := newDiagnostic(config, fmt.Sprintf("error creating application %s", appDN), err)
+		diagnostic.attribute = "application_attributes"
+		return diagnostic
+	}
+	d.Set("application_created", created)
+	d.Set("application_dn", appDN)
+	//the application is in state from now on, so destroy deletes it when associating or pushing fails
+	d.SetId(appDN)
+
+	log.Printf("[INFO] Associating certificate %s with application %s", certDN, appDN)
+	err = tppCertificateOperation(c, "Associate", map[string]interface{}{
+		"CertificateDN": certDN, "ApplicationDN": []string{appDN}, "PushToNew": false,
+	})
+	if err != nil {
+		return newDiagnostic(config, fmt.Sprintf("error associating certificate with application %s", appDN), err)
+	}
+
+	if d.Get("push").(bool) {
+		err = pushCertificate(c, d, d.Timeout(schema.TimeoutCreate))
+		if err != nil {
+			return newDiagnostic(config, fmt.Sprintf("error pushing certificate to application %s", appDN), err)
+		}
+	}
+	return resourceVenafiCertificateInstallationRead(d, meta)
+}
+
+// resourceVenafiCertificateInstallationCustomizeDiff plans an update which associates the certificate again
+func resourceVenafiCertificateInstallationCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() != "" && d.Get("provisioning_status").(string) == provisioningStatusDissociated {
+		return d.SetNewComputed("provisioning_status")
+	}
+	return nil
+}
+
+func resourceVenafiCertificateInstallationUpdate(d *schema.ResourceData, meta interface{}) error {
+	c, err := tppInstallationClient(meta)
+	if err != nil {
+		return err
+	}
+	config := meta.(*providerConfig)
+	push := d.HasChange("certificate_thumbprint") || d.HasChange("push")
+	details, err := readCertificateDetails(c, d.Get("certificate_guid").(string))
+	if err != nil {
+		return newDiagnostic(config, "error reading certificate", err)
+	}
+	if !isConsumer(details, d.Id()) {
+		log.Printf("[INFO] Associating certificate %s with application %s again", d.Get("certificate_dn"), d.Id())
+		err = tppCertificateOperation(c, "Associate", map[string]interface{}{
+			"CertificateDN": d.Get("certificate_dn").(string), "ApplicationDN": []string{d.Id()}, "PushToNew": false,
+		})
+		if err != nil {
+			return newDiagnostic(config, fmt.Sprintf("error associating certificate with application %s", d.Id()), err)
+		}
+		push = true
+	}
+	if d.Get("push").(bool) && push {
+		err = pushCertificate(c, d, d.Timeout(schema.TimeoutUpdate))
+		if err != nil {
+			return newDiagnostic(config, fmt.Sprintf("error pushing certificate to application %s", d.Id()), err)
+		}
+	}
+	return resourceVenafiCertificateInstallationRead(d, meta)
+}
+
+// pushCertificate pushes the certificate to the application and waits up to timeout for provisioning when configured
+func pushCertificate(c *restClient, d *schema.ResourceData, timeout time.Duration) error {
+	certDN := d.Get("certificate_dn").(string)
+	log.Printf("[INFO] Pushing certificate %s to application %s", certDN, d.Id())
+	err := tppCertificateOperation(c, "Push", map[string]interface{}{
+		"CertificateDN": certDN, "ApplicationDN": []string{d.Id()}, "PushToAll": false,
+	})
+	if err != nil {
+		return err
+	}
+	if !d.Get("wait_for_provisioning").(bool) {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		details, err := readCertificateDetails(c, d.Get("certificate_guid").(string))
+		if err != nil {
+			return err
+		}
+		processing := details.ProcessingDetails
+		if processing.InError {
+			return fmt.Errorf("provisioning of certificate %s to %s failed: %s", certDN, d.Id(), processing.Status)
+		}
+		if processing.Stage == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for provisioning of certificate %s to %s, last status: %s", timeout, certDN, d.Id(), processing.Status)
+		}
+		log.Printf("[DEBUG] Provisioning of certificate %s is at stage %d: %s", certDN, processing.Stage, processing.Status)
+		if err = sleepContext(c.context(), provisioningInterval); err != nil {
+			return fmt.Errorf("interrupted while waiting for provisioning of certificate %s to %s, last status: %s", certDN, d.Id(), processing.Status)
+		}
+	}
+}
+
+func readCertificateDetails(c *restClient, guid string) (*tppCertificateDetails, error) {
+	var details tppCertificateDetails
+	err := c.request("GET", "Certificates/"+url.PathEscape(guid), nil, &details)
+	if err != nil {
+		return nil, fmt.Errorf("error reading certificate %s: %s", guid, err)
+	}
+	return &details, nil
+}
+
+func isConsumer(details *tppCertificateDetails, appDN string) bool {
+	for _, consumer := range details.Consumers {
+		if strings.EqualFold(consumer, appDN) {
+			return true
+		}
+	}
+	return false
+}
+
+func resourceVenafiCertificateInstallationRead(d *schema.ResourceData, meta interface{}) error {
+	c, err := tppInstallationClient(meta)
+	if err != nil {
+		return err
+	}
+	config := meta.(*providerConfig)
+	appDN := d.Id()
+	exists, err := tppObjectExists(c, appDN)
+	if err != nil {
+		return newDiagnostic(config, fmt.Sprintf("error looking up application %s", appDN), err)
+	}
+	if !exists {
+		log.Printf("[WARN] Application %s doesn't exist, removing it from state", appDN)
+		d.SetId("")
+		return nil
+	}
+	details, err := readCertificateDetails(c, d.Get("certificate_guid").(string))
+	if err != nil {
+		return newDiagnostic(config, "error reading certificate", err)
+	}
+	if !isConsumer(details, appDN) {
+		log.Printf("[WARN] Certificate %s isn't associated with %s anymore", d.Get("certificate_dn"), appDN)
+		d.Set("provisioning_status", provisioningStatusDissociated)
+		d.Set("provisioning_details", "")
+		return nil
+	}
+
+	lastPushed := ""
+	res, err := tppConfig(c, "Read", map[string]interface{}{"ObjectDN": appDN, "AttributeName": "Last Pushed On"})
+	if err == nil && len(res.Values) > 0 {
+		lastPushed = res.Values[0]
+	}
+
+	processing := details.ProcessingDetails
+	status := provisioningStatusAssociated
+	switch {
+	case processing.InError:
+		status = provisioningStatusFailed
+	case processing.Stage > 0:
+		status = provisioningStatusPending
+	case lastPushed != "":
+		status = provisioningStatusInstalled
+	}
+	d.Set("provisioning_status", status)
+	d.Set("provisioning_details", processing.Status)
+	d.Set("last_pushed_on", lastPushed)
+	return nil
+}
+
+func resourceVenafiCertificateInstallationDelete(d *schema.ResourceData, meta interface{}) error {
+	c, err := tppInstallationClient(meta)
+	if err != nil {
+		return err
+	}
+	appDN := d.Id()
+	details, err := readCertificateDetails(c, d.Get("certificate_guid").(string))
+	if err != nil {
+		return newDiagnostic(meta.(*providerConfig), "error reading certificate", err)
+	}
+	//creation may have failed before the certificate was associated
+	if isConsumer(details, appDN) {
+		log.Printf("[INFO] Dissociating certificate %s from application %s", d.Get("certificate_dn"), appDN)
+		err = tppCertificateOperation(c, "Dissociate", map[string]interface{}{
+			"CertificateDN": d.Get("certificate_dn").(string), "ApplicationDN": []string{appDN}, "DeleteOrphans": false,
+		})
+		if err != nil {
+			return newDiagnostic(meta.(*providerConfig), fmt.Sprintf("error dissociating certificate from application %s", appDN), err)
+		}
+	}
+	if d.Get("application_created").(bool) {
+		log.Printf("[INFO] Deleting application %s", appDN)
+		_, err = tppConfig(c, "Delete", map[string]interface{}{"ObjectDN": appDN, "Recursive": false})
+		if err != nil {
+			return newDiagnostic(meta.(*providerConfig), fmt.Sprintf("error deleting application %s", appDN), err)
+		}
+	}
+	if d.Get("device_created").(bool) {
+		deviceDN := d.Get("device_dn").(string)
+		log.Printf("[INFO] Deleting device %s", deviceDN)
+		//other applications may have been added to the device since, it's kept then
+		_, err = tppConfig(c, "Delete", map[string]interface{}{"ObjectDN": deviceDN, "Recursive": false})
+		if err != nil {
+			log.Printf("[WARN] Device %s was kept: %s", deviceDN, err)
+		}
+	}
+	d.SetId("")
+	return nil
+}
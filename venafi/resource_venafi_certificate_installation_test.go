@@ -0,0 +1,277 @@
+package venafi
+
+import (
+	"encoding/json"
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testInstallationCertDN   = "\\VED\\Policy\\devops\\web.venafi.example"
+	testInstallationCertGUID = "{5fc5bd8a-1c0c-4c9a-8b8e-0d6e0b0f7d11}"
+)
+
+// tppApplicationStore keeps devices, applications and the certificate consumers of the WebSDK mock
+type tppApplicationStore struct {
+	sync.Mutex
+	objects    map[string]string
+	attributes map[string]map[string]string
+	consumers  map[string]bool
+	pushes     int
+	//polls left until the push is completed
+	pending int
+	status  string
+	inError bool
+}
+
+func newTPPApplicationStore() *tppApplicationStore {
+	return &tppApplicationStore{
+		objects:    map[string]string{testInstallationCertDN: tppClassX509Certificate, "\\VED\\Policy\\devops": tppClassPolicy},
+		attributes: map[string]map[string]string{},
+		consumers:  map[string]bool{},
+	}
+}
+
+func (s *tppApplicationStore) handlers(t *testing.T) map[string]http.HandlerFunc {
+	handle := func(f func(req map[string]interface{}) interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			s.Lock()
+			defer s.Unlock()
+			json.NewEncoder(w).Encode(f(req))
+		}
+	}
+	absent := map[string]interface{}{"Result": 400, "Error": "Object does not exist"}
+	applications := func(req map[string]interface{}) []string {
+		if req["CertificateDN"] != testInstallationCertDN {
+			t.Errorf("unexpected certificate %s", req["CertificateDN"])
+		}
+		return toStringSlice(req["ApplicationDN"].([]interface{}))
+	}
+	return map[string]http.HandlerFunc{
+		"/vedsdk/Config/DnToGuid": handle(func(req map[string]interface{}) interface{} {
+			if req["ObjectDN"] != testInstallationCertDN {
+				return absent
+			}
+			return map[string]interface{}{"Result": 1, "GUID": testInstallationCertGUID, "ClassName": tppClassX509Certificate}
+		}),
+		"/vedsdk/Config/IsValid": handle(func(req map[string]interface{}) interface{} {
+			if _, ok := s.objects[req["ObjectDN"].(string)]; !ok {
+				return absent
+			}
+			return map[string]interface{}{"Result": 1}
+		}),
+		"/vedsdk/Config/Create": handle(func(req map[string]interface{}) interface{} {
+			dn := req["ObjectDN"].(string)
+			if _, ok := s.objects[tppParentDN(dn)]; !ok {
+				return map[string]interface{}{"Result": 400, "Error": "Parent does not exist"}
+			}
+			s.objects[dn] = req["Class"].(string)
+			s.attributes[dn] = map[string]string{}
+			if list, ok := req["NameAttributeList"].([]interface{}); ok {
+				for _, a := range list {
+					attribute := a.(map[string]interface{})
+					s.attributes[dn][attribute["Name"].(string)] = attribute["Value"].(string)
+				}
+			}
+			return map[string]interface{}{"Result": 1}
+		}),
+		"/vedsdk/Config/Read": handle(func(req map[string]interface{}) interface{} {
+			v, ok := s.attributes[req["ObjectDN"].(string)][req["AttributeName"].(string)]
+			if !ok {
+				return map[string]interface{}{"Result": 1, "Values": []string{}}
+			}
+			return map[string]interface{}{"Result": 1, "Values": []string{v}}
+		}),
+		"/vedsdk/Config/Delete": handle(func(req map[string]interface{}) interface{} {
+			dn := req["ObjectDN"].(string)
+			if req["Recursive"].(bool) {
+				t.Errorf("unexpected recursive delete of %s", dn)
+			}
+			for child := range s.objects {
+				if tppParentDN(child) == dn {
+					return map[string]interface{}{"Result": 400, "Error": "Object has children"}
+				}
+			}
+			delete(s.objects, dn)
+			return map[string]interface{}{"Result": 1}
+		}),
+		"/vedsdk/Certificates/Associate": handle(func(req map[string]interface{}) interface{} {
+			for _, app := range applications(req) {
+				if s.attributes[app]["Driver Name"] == "appreject" {
+					return map[string]interface{}{"Success": false, "Error": "Application driver rejects the certificate"}
+				}
+				s.consumers[app] = true
+			}
+			return map[string]interface{}{"Success": true}
+		}),
+		"/vedsdk/Certificates/Dissociate": handle(func(req map[string]interface{}) interface{} {
+			for _, app := range applications(req) {
+				delete(s.consumers, app)
+			}
+			return map[string]interface{}{"Success": true}
+		}),
+		"/vedsdk/Certificates/Push": handle(func(req map[string]interface{}) interface{} {
+			s.pushes++
+			s.pending = 1
+			s.inError = false
+			s.status = "Pushing certificate"
+			for _, app := range applications(req) {
+				if !s.consumers[app] {
+					t.Errorf("push to application %s which isn't associated", app)
+				}
+				if s.attributes[app]["Driver Name"] == "appslow" {
+					s.pending = 1000
+				}
+				if s.attributes[app]["Driver Name"] == "appfail" {
+					s.inError = true
+					s.status = "Unable to connect to device"
+					continue
+				}
+				s.attributes[app]["Last Pushed On"] = time.Now().UTC().Format(time.RFC3339)
+			}
+			return map[string]interface{}{"Success": true}
+		}),
+		"/vedsdk/Certificates/": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/vedsdk/Certificates/"+testInstallationCertGUID {
+				t.Errorf("unexpected request %s %s", r.Method, r.URL)
+				http.NotFound(w, r)
+				return
+			}
+			s.Lock()
+			defer s.Unlock()
+			var consumers []string
+			for app := range s.consumers {
+				consumers = append(consumers, app)
+			}
+			processing := map[string]interface{}{}
+			if s.inError {
+				processing = map[string]interface{}{"InError": true, "Stage": 800, "Status": s.status}
+			} else if s.pending > 0 {
+				processing = map[string]interface{}{"InError": false, "Stage": 800, "Status": s.status}
+				s.pending--
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Consumers": consumers, "ProcessingDetails": processing})
+		},
+	}
+}
+
+const tppCertificateInstallationResource = `
+resource "venafi_certificate_installation" "web" {
+  certificate_dn = "\\VED\\Policy\\devops\\web.venafi.example"
+  device_name = "web01"
+  device_host = "web01.venafi.example"
+  application_name = "apache"
+  application_class = "Apache"
+  application_attributes = {
+    "Driver Name" = "%s"
+    "Certificate File" = "/etc/httpd/ssl/web.crt"
+  }
+  certificate_thumbprint = "%s"
+}`
+
+func TestTPPCertificateInstallation(t *testing.T) {
+	provisioningInterval = 10 * time.Millisecond
+	store := newTPPApplicationStore()
+	server := newTPPTestServer(t, store.handlers(t))
+	defer server.Close()
+	deviceDN := "\\VED\\Policy\\devops\\web01"
+	appDN := deviceDN + "\\apache"
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			store.Lock()
+			defer store.Unlock()
+			if len(store.consumers) != 0 {
+				return fmt.Errorf("certificate is still associated with %v", store.consumers)
+			}
+			for _, dn := range []string{appDN, deviceDN} {
+				if _, ok := store.objects[dn]; ok {
+					return fmt.Errorf("%s was not deleted", dn)
+				}
+			}
+			return nil
+		},
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + fmt.Sprintf(tppCertificateInstallationResource, "appapache", "A1"),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate_installation.web", "id", appDN),
+					r.TestCheckResourceAttr("venafi_certificate_installation.web", "device_dn", deviceDN),
+					r.TestCheckResourceAttr("venafi_certificate_installation.web", "certificate_guid", testInstallationCertGUID),
+					r.TestCheckResourceAttr("venafi_certificate_installation.web", "provisioning_status", provisioningStatusInstalled),
+					r.TestCheckResourceAttr("venafi_certificate_installation.web", "device_created", "true"),
+					func(s *terraform.State) error {
+						store.Lock()
+						defer store.Unlock()
+						if store.objects[appDN] != "Apache" || store.attributes[appDN]["Certificate File"] != "/etc/httpd/ssl/web.crt" {
+							return fmt.Errorf("application wasn't created as configured: %v", store.attributes[appDN])
+						}
+						if store.attributes[deviceDN]["Host"] != "web01.venafi.example" {
+							return fmt.Errorf("device host wasn't set: %v", store.attributes[deviceDN])
+						}
+						if store.pushes != 1 {
+							return fmt.Errorf("expected 1 push, got %d", store.pushes)
+						}
+						return nil
+					},
+				),
+			},
+			r.TestStep{
+				//renewed certificate is pushed again
+				Config: tppTestProviderConfig(server) + fmt.Sprintf(tppCertificateInstallationResource, "appapache", "B2"),
+				Check: func(s *terraform.State) error {
+					store.Lock()
+					defer store.Unlock()
+					if store.pushes != 2 {
+						return fmt.Errorf("expected 2 pushes, got %d", store.pushes)
+					}
+					return nil
+				},
+			},
+			r.TestStep{
+				//removed association is restored
+				PreConfig: func() {
+					store.Lock()
+					defer store.Unlock()
+					delete(store.consumers, appDN)
+				},
+				Config: tppTestProviderConfig(server) + fmt.Sprintf(tppCertificateInstallationResource, "appapache", "B2"),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate_installation.web", "provisioning_status", provisioningStatusInstalled),
+					func(s *terraform.State) error {
+						store.Lock()
+						defer store.Unlock()
+						if !store.consumers[appDN] || store.pushes != 3 {
+							return fmt.Errorf("certificate wasn't associated and pushed again")
+						}
+						return nil
+					},
+				),
+			},
+			r.TestStep{
+				Config:      tppTestProviderConfig(server) + fmt.Sprintf(tppCertificateInstallationResource, "appfail", "B2"),
+				ExpectError: regexp.MustCompile("provisioning of certificate .* failed: Unable to connect to device"),
+			},
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + strings.Replace(fmt.Sprintf(tppCertificateInstallationResource, "appslow", "B2"),
+					`certificate_thumbprint = "B2"`, "certificate_thumbprint = \"B2\"\n  timeouts {\n    create = \"1s\"\n  }", 1),
+				ExpectError: regexp.MustCompile("timed out after 1s waiting for provisioning of certificate .*, last status: Pushing certificate"),
+			},
+			r.TestStep{
+				//the application is kept in state and deleted by destroy when it can't be associated
+				Config:      tppTestProviderConfig(server) + fmt.Sprintf(tppCertificateInstallationResource, "appreject", "B2"),
+				ExpectError: regexp.MustCompile("Certificates/Associate failed for .*: Application driver rejects the certificate"),
+			},
+		},
+	})
+}
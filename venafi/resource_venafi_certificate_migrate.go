@@ -0,0 +1,88 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform/terraform"
+	"log"
+	"strconv"
+	"strings"
+)
+
+func resourceVenafiCertificateMigrateState(v int, is *terraform.InstanceState, meta interface{}) (*terraform.InstanceState, error) {
+	switch v {
+	case 0:
+		log.Println("[INFO] Found venafi_certificate state v0, migrating to v2")
+		is, err := migrateVenafiCertificateStateV0toV1(is)
+		if err != nil {
+			return is, err
+		}
+		return migrateVenafiCertificateStateV1toV2(is)
+	case 1:
+		log.Println("[INFO] Found venafi_certificate state v1, migrating to v2")
+		return migrateVenafiCertificateStateV1toV2(is)
+	default:
+		return is, fmt.Errorf("unexpected schema version: %d", v)
+	}
+}
+
+// migrateVenafiCertificateStateV0toV1 replaces the pickup ID, which was the ID of version 0, with the ID returned
+// by certificateID. The pickup ID stays in certificate_dn. Migration works offline: Venafi Cloud request IDs are kept,
+// the next Read looks their certificate ID up.
+func migrateVenafiCertificateStateV0toV1(is *terraform.InstanceState) (*terraform.InstanceState, error) {
+	if is.Empty() {
+		log.Println("[DEBUG] Empty InstanceState, nothing to migrate")
+		return is, nil
+	}
+	if is.Attributes["certificate_dn"] == "" {
+		is.Attributes["certificate_dn"] = is.ID
+	}
+	id := is.ID
+	switch serial, dev := devSerial(is.ID); {
+	case strings.HasPrefix(is.ID, "\\"):
+		//Venafi Platform certificate DN
+	case dev && serial != "":
+		id = serial
+	case dev:
+		//vcert test mode pickup IDs only hold the CSR, version 0 states have the certificate but no serial_number
+		certs, err := parseCertificates(is.Attributes["certificate"])
+		if err != nil || len(certs) == 0 {
+			log.Printf("[WARN] Keeping pickup ID %s as ID of the dev mode certificate, it has no certificate: %v", is.ID, err)
+			break
+		}
+		id = certs[0].SerialNumber.Text(16)
+	default:
+		//Venafi Cloud request ID, certificate_dn equals the ID until Read replaces it with the certificate ID
+		log.Printf("[INFO] Keeping request ID %s as ID until the certificate ID is looked up on refresh", is.ID)
+	}
+	log.Printf("[DEBUG] Migrating certificate ID %s to %s", is.ID, id)
+	is.ID = id
+	is.Attributes["id"] = id
+	return is, nil
+}
+
+// migrateVenafiCertificateStateV1toV2 turns the SAN lists of version 1 into sets. Elements are kept as they were
+// configured, elements which are equal after normalization are kept once.
+func migrateVenafiCertificateStateV1toV2(is *terraform.InstanceState) (*terraform.InstanceState, error) {
+	if is.Empty() {
+		log.Println("[DEBUG] Empty InstanceState, nothing to migrate")
+		return is, nil
+	}
+	for key, normalize := range map[string]func(string) string{"san_dns": normalizeDNSName, "san_email": normalizeEmail, "san_ip": normalizeIP} {
+		count, err := strconv.Atoi(is.Attributes[key+".#"])
+		if err != nil {
+			continue
+		}
+		hash := sanSetFunc(normalize)
+		elements := map[string]string{}
+		for i := 0; i < count; i++ {
+			value := is.Attributes[fmt.Sprintf("%s.%d", key, i)]
+			delete(is.Attributes, fmt.Sprintf("%s.%d", key, i))
+			elements[strconv.Itoa(hash(value))] = value
+		}
+		for code, value := range elements {
+			is.Attributes[key+"."+code] = value
+		}
+		is.Attributes[key+".#"] = strconv.Itoa(len(elements))
+	}
+	return is, nil
+}
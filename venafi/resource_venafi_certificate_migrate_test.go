@@ -0,0 +1,102 @@
+package venafi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testCertificatePEM returns a self-signed certificate with the serial number
+func testCertificatePEM(t *testing.T, serial int64) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "migrate.venafi.example"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestVenafiCertificateMigrateState(t *testing.T) {
+	devID := base64.StdEncoding.EncodeToString([]byte(`{"CSR":"Q1NS","Serial":"1f2e"}`))
+	testModeID := base64.StdEncoding.EncodeToString([]byte(`{"CSR":"Q1NS"}`))
+
+	//version 0 states have certificate_dn, certificate, chain and private_key_pem but no serial_number
+	cases := []struct {
+		id         string
+		attributes map[string]string
+		expected   string
+	}{
+		{"\\VED\\Policy\\devops\\web.venafi.example", map[string]string{"certificate_dn": "\\VED\\Policy\\devops\\web.venafi.example"}, "\\VED\\Policy\\devops\\web.venafi.example"},
+		{devID, map[string]string{"certificate_dn": devID}, "1f2e"},
+		{testModeID, map[string]string{"certificate_dn": testModeID, "certificate": testCertificatePEM(t, 0x1f2e)}, "1f2e"},
+		//the certificate ID is looked up by Read, migration doesn't call Venafi Cloud
+		{"request-7", map[string]string{"certificate_dn": "request-7"}, "request-7"},
+		//pickup ID is kept when the certificate can't be parsed rather than failing every plan
+		{testModeID, map[string]string{"certificate_dn": testModeID, "certificate": "corrupted"}, testModeID},
+	}
+	for _, c := range cases {
+		is := &terraform.InstanceState{ID: c.id, Attributes: c.attributes}
+		is, err := resourceVenafiCertificateMigrateState(0, is, nil)
+		if err != nil {
+			t.Errorf("error migrating %s: %s", c.id, err)
+			continue
+		}
+		if is.ID != c.expected || is.Attributes["id"] != c.expected {
+			t.Errorf("expected ID %s after migrating %s, got %s", c.expected, c.id, is.ID)
+		}
+		if is.Attributes["certificate_dn"] != c.id {
+			t.Errorf("pickup ID %s wasn't kept in certificate_dn, got %s", c.id, is.Attributes["certificate_dn"])
+		}
+	}
+}
+
+func TestVenafiCertificateMigrateStateV1toV2(t *testing.T) {
+	is := &terraform.InstanceState{ID: "\\VED\\Policy\\devops\\web.venafi.example", Attributes: map[string]string{
+		"certificate_dn": "\\VED\\Policy\\devops\\web.venafi.example",
+		"san_dns.#":      "3",
+		"san_dns.0":      "web.venafi.example",
+		"san_dns.1":      "API.venafi.example",
+		"san_dns.2":      "api.venafi.example.",
+		"san_ip.#":       "1",
+		"san_ip.0":       "2001:DB8::1",
+	}}
+	is, err := resourceVenafiCertificateMigrateState(1, is, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := resourceVenafiCertificate().Data(is)
+	expected := map[string]*schema.Set{
+		"san_dns":   schema.NewSet(sanSetFunc(normalizeDNSName), []interface{}{"api.venafi.example", "WEB.venafi.example"}),
+		"san_email": schema.NewSet(sanSetFunc(normalizeEmail), []interface{}{}),
+		"san_ip":    schema.NewSet(sanSetFunc(normalizeIP), []interface{}{"2001:db8::1"}),
+	}
+	for key, set := range expected {
+		//sets compare by hash, which is the same for values equal after normalization
+		got := d.Get(key).(*schema.Set)
+		if got.Len() != set.Len() || got.Difference(set).Len() != 0 {
+			t.Errorf("expected %s %v after migration, got %v", key, set.List(), got.List())
+		}
+	}
+	if _, ok := is.Attributes["san_dns.0"]; ok {
+		t.Errorf("list element san_dns.0 wasn't removed: %v", is.Attributes)
+	}
+}
@@ -0,0 +1,781 @@
+package venafi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockCertificate is a certificate issued by the Venafi Platform and Cloud stand-ins
+type mockCertificate struct {
+	csr          string
+	certificate  string
+	pendingPolls int
+	failed       bool
+	revoked      bool
+	reason       float64
+}
+
+// mockCA issues certificates of the stand-ins with the dev mode CA
+type mockCA struct {
+	sync.Mutex
+	ca *devCA
+	//pendingPolls is the number of retrievals a new certificate stays pending
+	pendingPolls int
+	requests     int
+	renewals     int
+}
+
+func newMockCA(t *testing.T, validityHours int) *mockCA {
+	ca, err := newDevCA("", "", validityHours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &mockCA{ca: ca}
+}
+
+func (m *mockCA) issue(c *mockCertificate, csrPEM string) error {
+	csr, err := parseCSR(csrPEM)
+	if err != nil {
+		return err
+	}
+	cert, err := m.ca.issue(csr)
+	if err != nil {
+		return err
+	}
+	c.csr = csrPEM
+	c.certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	return nil
+}
+
+// chain returns the certificate PEM with the CA before or after it
+func (m *mockCA) chain(c *mockCertificate, rootFirst bool) string {
+	if rootFirst {
+		return m.ca.certificatePEM + c.certificate
+	}
+	return c.certificate + m.ca.certificatePEM
+}
+
+// commonName returns the subject common name of a PEM CSR
+func commonName(csrPEM string) string {
+	csr, err := parseCSR(csrPEM)
+	if err != nil {
+		return ""
+	}
+	return csr.Subject.CommonName
+}
+
+// tppCertificateMock is a Venafi Platform WebSDK issuing certificates into the policy folders of tppPolicyStore
+type tppCertificateMock struct {
+	*mockCA
+	policies     *tppPolicyStore
+	certificates map[string]*mockCertificate
+	//rejectCN is refused like a common name which isn't in the policy whitelist
+	rejectCN    string
+	renewError  string
+	revocations int
+}
+
+func newTPPCertificateMock(t *testing.T, validityHours int) *tppCertificateMock {
+	return &tppCertificateMock{
+		mockCA:       newMockCA(t, validityHours),
+		policies:     &tppPolicyStore{objects: map[string]bool{"\\VED\\Policy\\devops": true}, attributes: map[string]tppPolicyValue{}},
+		certificates: map[string]*mockCertificate{},
+	}
+}
+
+func (m *tppCertificateMock) policyExists(dn string) bool {
+	m.policies.Lock()
+	defer m.policies.Unlock()
+	return m.policies.objects[dn]
+}
+
+func (m *tppCertificateMock) handlers(t *testing.T) map[string]http.HandlerFunc {
+	handle := func(f func(req map[string]interface{}) (int, interface{})) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			m.Lock()
+			defer m.Unlock()
+			status, res := f(req)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(res)
+		}
+	}
+	str := func(v interface{}) string {
+		s, _ := v.(string)
+		return s
+	}
+	handlers := m.policies.handlers(t)
+	handlers["/vedsdk/certificates/checkpolicy"] = handle(func(req map[string]interface{}) (int, interface{}) {
+		if !m.policyExists(str(req["PolicyDN"])) {
+			return http.StatusBadRequest, map[string]interface{}{"Error": "Policy folder does not exist"}
+		}
+		return http.StatusOK, map[string]interface{}{"Policy": map[string]interface{}{
+			"SubjAltNameDnsAllowed":   true,
+			"SubjAltNameIpAllowed":    true,
+			"SubjAltNameEmailAllowed": true,
+			"WildcardsAllowed":        true,
+		}}
+	})
+	handlers["/vedsdk/certificates/request"] = handle(func(req map[string]interface{}) (int, interface{}) {
+		policyDN, csrPEM := str(req["PolicyDN"]), str(req["PKCS10"])
+		if !m.policyExists(policyDN) {
+			return http.StatusBadRequest, map[string]interface{}{"Error": "Policy folder does not exist"}
+		}
+		cn := commonName(csrPEM)
+		if cn == m.rejectCN {
+			return http.StatusBadRequest, map[string]interface{}{"Error": fmt.Sprintf("%s is not in the domain whitelist", cn)}
+		}
+		c := &mockCertificate{pendingPolls: m.pendingPolls}
+		if err := m.issue(c, csrPEM); err != nil {
+			return http.StatusBadRequest, map[string]interface{}{"Error": err.Error()}
+		}
+		dn := policyDN + "\\" + cn
+		m.certificates[dn] = c
+		m.requests++
+		return http.StatusOK, map[string]interface{}{"CertificateDN": dn}
+	})
+	handlers["/vedsdk/certificates/retrieve"] = handle(func(req map[string]interface{}) (int, interface{}) {
+		c, ok := m.certificates[str(req["CertificateDN"])]
+		if !ok {
+			return http.StatusBadRequest, map[string]interface{}{"Error": "Certificate does not exist"}
+		}
+		if c.pendingPolls > 0 {
+			c.pendingPolls--
+			return http.StatusAccepted, map[string]interface{}{"Stage": 500, "Status": "Pending approval"}
+		}
+		data := c.certificate
+		if includeChain, _ := req["IncludeChain"].(bool); includeChain {
+			rootFirst, _ := req["RootFirstOrder"].(bool)
+			data = m.chain(c, rootFirst)
+		}
+		return http.StatusOK, map[string]interface{}{"CertificateData": base64.StdEncoding.EncodeToString([]byte(data))}
+	})
+	handlers["/vedsdk/certificates/renew"] = handle(func(req map[string]interface{}) (int, interface{}) {
+		c, ok := m.certificates[str(req["CertificateDN"])]
+		switch {
+		case !ok:
+			return http.StatusOK, map[string]interface{}{"Success": false, "Error": "Certificate does not exist"}
+		case m.renewError != "":
+			return http.StatusOK, map[string]interface{}{"Success": false, "Error": m.renewError}
+		}
+		csrPEM := str(req["PKCS10"])
+		if csrPEM == "" {
+			csrPEM = c.csr
+		}
+		if err := m.issue(c, csrPEM); err != nil {
+			return http.StatusOK, map[string]interface{}{"Success": false, "Error": err.Error()}
+		}
+		m.renewals++
+		return http.StatusOK, map[string]interface{}{"Success": true}
+	})
+	handlers["/vedsdk/certificates/revoke"] = handle(func(req map[string]interface{}) (int, interface{}) {
+		c, ok := m.certificates[str(req["CertificateDN"])]
+		if !ok {
+			return http.StatusBadRequest, map[string]interface{}{"Success": false, "Error": "Certificate does not exist"}
+		}
+		if c.revoked {
+			return http.StatusOK, map[string]interface{}{"Requested": false, "Success": true}
+		}
+		c.revoked = true
+		c.reason, _ = req["Reason"].(float64)
+		m.revocations++
+		return http.StatusOK, map[string]interface{}{"Requested": true, "Success": true}
+	})
+	return handlers
+}
+
+// cloudCertificateMock is a Venafi Cloud API with the Default zone allowing RSA keys for *.venafi.example
+type cloudCertificateMock struct {
+	*mockCA
+	certificates map[string]*mockCertificate
+	//failCN fails issuance like a CA rejecting the request
+	failCN string
+	//hideCertificateIDs leaves the certificate IDs of issued requests out, like before they are indexed
+	hideCertificateIDs bool
+}
+
+func newCloudCertificateMock(t *testing.T, validityHours int) *cloudCertificateMock {
+	return &cloudCertificateMock{mockCA: newMockCA(t, validityHours), certificates: map[string]*mockCertificate{}}
+}
+
+func cloudErrors(w http.ResponseWriter, status int, code int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]interface{}{{"code": code, "message": message}}})
+}
+
+// newCloudTestServer starts the Venafi Cloud stand-in. vcert uses http.DefaultClient for Venafi Cloud,
+// so its transport trusts the server until the returned function is called.
+func newCloudTestServer(t *testing.T, m *cloudCertificateMock) (*httptest.Server, func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/ping", func(w http.ResponseWriter, r *http.Request) {})
+	api := func(path string, f func(w http.ResponseWriter, r *http.Request)) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("tppl-api-key") != "test-api-key" {
+				cloudErrors(w, http.StatusUnauthorized, 10501, "Invalid API key")
+				return
+			}
+			m.Lock()
+			defer m.Unlock()
+			f(w, r)
+		})
+	}
+	api("/v1/useraccounts", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"user": {"username": "ci@venafi.example", "id": "user-id", "companyId": "company-id"}, "company": {"id": "company-id", "name": "Venafi"}}`)
+	})
+	api("/v1/zones/tag/", func(w http.ResponseWriter, r *http.Request) {
+		tag := strings.TrimPrefix(r.URL.Path, "/v1/zones/tag/")
+		if tag != "Default" {
+			cloudErrors(w, http.StatusNotFound, 10051, fmt.Sprintf("Unable to find zone with tag %s", tag))
+			return
+		}
+		fmt.Fprint(w, `{"id": "zone-id", "tag": "Default", "defaultCertificateIdentityPolicyId": "identity-id", "defaultCertificateUsePolicyId": "use-id"}`)
+	})
+	api("/v1/certificatepolicies/", func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/v1/certificatepolicies/") {
+		case "identity-id":
+			fmt.Fprint(w, `{"certificatePolicyType": "CERTIFICATE_IDENTITY", "id": "identity-id", "subjectCNRegexes": [".*\\.venafi\\.example"],
+"subjectORegexes": [".*"], "subjectOURegexes": [".*"], "subjectSTRegexes": [".*"], "subjectLRegexes": [".*"], "subjectCValues": [".*"], "sanRegexes": [".*\\.venafi\\.example"]}`)
+		case "use-id":
+			fmt.Fprint(w, `{"certificatePolicyType": "CERTIFICATE_USE", "id": "use-id", "keyTypes": [{"keyType": "RSA", "keyLengths": [2048, 4096]}], "keyReuse": false}`)
+		default:
+			cloudErrors(w, http.StatusNotFound, 10052, "Unable to find policy")
+		}
+	})
+	api("/v1/certificaterequests", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			CSR    string `json:"certificateSigningRequest"`
+			ZoneID string `json:"zoneId"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ZoneID != "zone-id" {
+			t.Errorf("unexpected zone %s", req.ZoneID)
+		}
+		c := &mockCertificate{pendingPolls: m.pendingPolls, failed: commonName(req.CSR) == m.failCN}
+		if err := m.issue(c, req.CSR); err != nil {
+			cloudErrors(w, http.StatusBadRequest, 10303, err.Error())
+			return
+		}
+		m.requests++
+		id := fmt.Sprintf("request-%d", m.requests)
+		m.certificates[id] = c
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"certificateRequests": [{"id": "%s", "zoneId": "zone-id", "status": "REQUESTED"}]}`, id)
+	})
+	api("/v1/certificaterequests/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/certificaterequests/"), "/")
+		c, ok := m.certificates[path[0]]
+		if !ok {
+			cloudErrors(w, http.StatusNotFound, 10404, "Certificate request not found")
+			return
+		}
+		if len(path) == 2 && path[1] == "certificate" {
+			fmt.Fprint(w, m.chain(c, r.URL.Query().Get("chainOrder") == "ROOT_FIRST"))
+			return
+		}
+		status := "ISSUED"
+		switch {
+		case c.failed:
+			status = "FAILED"
+		case c.pendingPolls > 0:
+			c.pendingPolls--
+			status = "PENDING"
+		}
+		certificateIDs := "[]"
+		if status == "ISSUED" && !m.hideCertificateIDs {
+			certificateIDs = fmt.Sprintf(`["%s"]`, strings.Replace(path[0], "request", "certificate", 1))
+		}
+		fmt.Fprintf(w, `{"id": "%s", "zoneId": "zone-id", "status": "%s", "certificateIds": %s}`, path[0], status, certificateIDs)
+	})
+	server := httptest.NewTLSServer(mux)
+
+	transport := http.DefaultTransport.(*http.Transport)
+	previous := transport.TLSClientConfig
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	return server, func() {
+		server.Close()
+		transport.TLSClientConfig = previous
+	}
+}
+
+// cloudTestProviderConfig returns a provider block for the Venafi Cloud stand-in, vcert appends v1/ to the URL without a slash
+func cloudTestProviderConfig(server *httptest.Server, apiKey string, zone string) string {
+	return fmt.Sprintf(`
+provider "venafi" {
+  url = "%s/"
+  api_key = "%s"
+  zone = "%s"
+}`, server.URL, apiKey, zone)
+}
+
+// checkMockCertificate verifies the issued certificate against the stand-in CA and returns its serial number
+func checkMockCertificate(s *terraform.State, name string, m *mockCA, cn string) (string, error) {
+	attrs := s.RootModule().Resources[name].Primary.Attributes
+	if attrs["chain"] != m.ca.certificatePEM {
+		return "", fmt.Errorf("unexpected chain %s", attrs["chain"])
+	}
+	certs, err := parseCertificates(attrs["certificate"])
+	if err != nil || len(certs) != 1 {
+		return "", fmt.Errorf("expected a single certificate, got %d: %v", len(certs), err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(m.ca.certificate)
+	if _, err = certs[0].Verify(x509.VerifyOptions{Roots: roots, DNSName: cn}); err != nil {
+		return "", fmt.Errorf("certificate isn't issued by the stand-in CA: %s", err)
+	}
+	if _, err = tls.X509KeyPair([]byte(attrs["certificate"]), []byte(attrs["private_key_pem"])); err != nil {
+		return "", err
+	}
+	return attrs["serial_number"], nil
+}
+
+const tppMockCertificateConfig = `
+resource "venafi_policy" "team" {
+  name = "team"
+  parent_dn = "devops"
+  force_destroy = true
+}
+resource "venafi_certificate" "tpp" {
+  common_name = "%s"
+  san_dns = ["alt.venafi.example"]
+  san_ip = ["192.168.1.1"]
+  expiration_window = %d
+  revoke_on_destroy = true
+  revocation_reason = "key-compromise"
+  depends_on = ["venafi_policy.team"]
+}`
+
+// tppMockProviderConfig points the provider to the team policy folder of tppMockCertificateConfig
+func tppMockProviderConfig(server *httptest.Server) string {
+	return strings.Replace(tppTestProviderConfig(server), `zone = "devops"`, `zone = "devops\\team"`, 1)
+}
+
+func TestTPPMockCertificateLifecycle(t *testing.T) {
+	m := newTPPCertificateMock(t, 24*90)
+	m.pendingPolls = 1
+	server := newTPPTestServer(t, m.handlers(t))
+	defer server.Close()
+	cn := "lifecycle.venafi.example"
+	dn := "\\VED\\Policy\\devops\\team\\" + cn
+	config := tppMockProviderConfig(server) + fmt.Sprintf(tppMockCertificateConfig, cn, 168)
+
+	var serial string
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			m.Lock()
+			defer m.Unlock()
+			if c := m.certificates[dn]; !c.revoked || c.reason != 1 {
+				return fmt.Errorf("certificate wasn't revoked for key compromise on destroy")
+			}
+			if m.policyExists("\\VED\\Policy\\devops\\team") {
+				return fmt.Errorf("policy folder wasn't deleted")
+			}
+			return nil
+		},
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate.tpp", "id", dn),
+					r.TestCheckResourceAttr("venafi_certificate.tpp", "certificate_dn", dn),
+					func(s *terraform.State) (err error) {
+						m.Lock()
+						defer m.Unlock()
+						if serial, err = checkMockCertificate(s, "venafi_certificate.tpp", m.mockCA, cn); err != nil {
+							return err
+						}
+						if m.requests != 1 || m.certificates[dn].pendingPolls != 0 {
+							return fmt.Errorf("expected 1 request which was pending once, got %d", m.requests)
+						}
+						return nil
+					},
+				),
+			},
+			r.TestStep{
+				Config: config,
+				Check: func(s *terraform.State) error {
+					m.Lock()
+					defer m.Unlock()
+					got, err := checkMockCertificate(s, "venafi_certificate.tpp", m.mockCA, cn)
+					if err != nil {
+						return err
+					}
+					if got != serial || m.requests != 1 || m.renewals != 0 {
+						return fmt.Errorf("certificate %s was replaced by %s without reason", serial, got)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestTPPMockCertificateRenewal(t *testing.T) {
+	//certificates expire in 176 hours, so every refresh renews them with the expiration window of 190 hours
+	m := newTPPCertificateMock(t, 200)
+	server := newTPPTestServer(t, m.handlers(t))
+	defer server.Close()
+	cn := "renewal.venafi.example"
+	dn := "\\VED\\Policy\\devops\\team\\" + cn
+	config := tppMockProviderConfig(server) + fmt.Sprintf(tppMockCertificateConfig, cn, 190)
+
+	var serial, fingerprint string
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+				Check: func(s *terraform.State) (err error) {
+					m.Lock()
+					defer m.Unlock()
+					fingerprint = s.RootModule().Resources["venafi_certificate.tpp"].Primary.Attributes["private_key_fingerprint"]
+					serial, err = checkMockCertificate(s, "venafi_certificate.tpp", m.mockCA, cn)
+					return err
+				},
+			},
+			r.TestStep{
+				Config: config,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate.tpp", "id", dn),
+					func(s *terraform.State) error {
+						m.Lock()
+						defer m.Unlock()
+						got, err := checkMockCertificate(s, "venafi_certificate.tpp", m.mockCA, cn)
+						if err != nil {
+							return err
+						}
+						if got == serial || m.renewals == 0 || m.requests != 1 {
+							return fmt.Errorf("certificate %s wasn't renewed, %d renewals and %d requests", serial, m.renewals, m.requests)
+						}
+						if s.RootModule().Resources["venafi_certificate.tpp"].Primary.Attributes["private_key_fingerprint"] == fingerprint {
+							return fmt.Errorf("renewal reused the private key")
+						}
+						return nil
+					},
+				),
+			},
+			r.TestStep{
+				PreConfig: func() {
+					m.Lock()
+					defer m.Unlock()
+					m.renewError = "Certificate is disabled"
+				},
+				Config:      config,
+				ExpectError: regexp.MustCompile(`(?s)error renewing certificate: Certificate Renewal error: Certificate is disabled.*pickup ID: \\VED\\Policy\\devops\\team\\renewal\.venafi\.example`),
+			},
+			r.TestStep{
+				PreConfig: func() {
+					m.Lock()
+					defer m.Unlock()
+					m.renewError = ""
+				},
+				Config: config,
+			},
+		},
+	})
+}
+
+func TestTPPMockCertificateRevocationReason(t *testing.T) {
+	m := newTPPCertificateMock(t, 24*90)
+	server := newTPPTestServer(t, m.handlers(t))
+	defer server.Close()
+	cn := "revocation.venafi.example"
+	dn := "\\VED\\Policy\\devops\\team\\" + cn
+	config := tppMockProviderConfig(server) + fmt.Sprintf(tppMockCertificateConfig, cn, 168)
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			m.Lock()
+			defer m.Unlock()
+			if c := m.certificates[dn]; !c.revoked || c.reason != 4 || m.revocations != 1 {
+				return fmt.Errorf("certificate wasn't revoked once as superseded on destroy, %d revocations", m.revocations)
+			}
+			return nil
+		},
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+			},
+			r.TestStep{
+				//revocation settings are updated in place
+				Config: strings.Replace(config, "key-compromise", "superseded", 1),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate.tpp", "revocation_reason", "superseded"),
+					func(s *terraform.State) error {
+						m.Lock()
+						defer m.Unlock()
+						if m.requests != 1 || m.renewals != 0 || m.revocations != 0 {
+							return fmt.Errorf("changing revocation_reason replaced the certificate, %d requests, %d renewals and %d revocations", m.requests, m.renewals, m.revocations)
+						}
+						return nil
+					},
+				),
+			},
+			r.TestStep{
+				Config:      strings.Replace(config, "key-compromise", "lost", 1),
+				ExpectError: regexp.MustCompile("revocation_reason must be one of none, key-compromise, ca-compromise, affiliation-changed, superseded or cessation-of-operation, got lost"),
+			},
+			r.TestStep{
+				Config: strings.Replace(config, "key-compromise", "superseded", 1),
+			},
+		},
+	})
+}
+
+func TestTPPMockCertificateErrors(t *testing.T) {
+	m := newTPPCertificateMock(t, 24*90)
+	m.rejectCN = "rejected.venafi.example"
+	server := newTPPTestServer(t, m.handlers(t))
+	defer server.Close()
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config:      tppMockProviderConfig(server) + fmt.Sprintf(tppMockCertificateConfig, "rejected.venafi.example", 168),
+				ExpectError: regexp.MustCompile("Status: 400 Bad Request.*rejected.venafi.example is not in the domain whitelist"),
+			},
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + `
+resource "venafi_certificate" "tpp" {
+  common_name = "missing.venafi.example"
+}`,
+				PreConfig: func() {
+					m.policies.Lock()
+					defer m.policies.Unlock()
+					delete(m.policies.objects, "\\VED\\Policy\\devops")
+				},
+				ExpectError: regexp.MustCompile(`(?s)could not read zone configuration: Invalid status: 400 Bad Request.*attribute: zone.*zone: devops.*HTTP status: 400 Bad Request`),
+			},
+			r.TestStep{
+				Config: strings.Replace(tppTestProviderConfig(server), `"secret"`, `"wrong"`, 1) + `
+resource "venafi_certificate" "tpp" {
+  common_name = "unauthorized.venafi.example"
+}`,
+				ExpectError: regexp.MustCompile(`(?s)Unexpected status code on TPP Authorize. Status: 401 Unauthorized.*attribute: tpp_username.*backend: Venafi Platform at https://.*suggestion: check tpp_username and tpp_password`),
+			},
+		},
+	})
+}
+
+const tppMockZoneConfig = `
+resource "venafi_policy" "team" {
+  name = "team"
+  parent_dn = "devops"
+  force_destroy = true
+}
+resource "venafi_certificate" "team" {
+  common_name = "team.venafi.example"
+  zone = "devops\\team"
+  depends_on = ["venafi_policy.team"]
+}
+resource "venafi_certificate" "provider" {
+  common_name = "provider.venafi.example"
+}`
+
+func TestTPPMockCertificateZone(t *testing.T) {
+	m := newTPPCertificateMock(t, 24*90)
+	server := newTPPTestServer(t, m.handlers(t))
+	defer server.Close()
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + tppMockZoneConfig,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate.team", "id", "\\VED\\Policy\\devops\\team\\team.venafi.example"),
+					r.TestCheckResourceAttr("venafi_certificate.provider", "id", "\\VED\\Policy\\devops\\provider.venafi.example"),
+				),
+			},
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + `
+resource "venafi_certificate" "missing" {
+  common_name = "missing.venafi.example"
+  zone = "devops\\missing"
+}`,
+				ExpectError: regexp.MustCompile(`(?s)could not read zone configuration.*zone: devops\\missing`),
+			},
+		},
+	})
+}
+
+const cloudMockCertificateConfig = `
+resource "venafi_certificate" "cloud" {
+  common_name = "%s"
+  expiration_window = %d
+}`
+
+func TestCloudMockCertificateLifecycle(t *testing.T) {
+	m := newCloudCertificateMock(t, 24*90)
+	m.pendingPolls = 1
+	server, closeServer := newCloudTestServer(t, m)
+	defer closeServer()
+	cn := "lifecycle.venafi.example"
+	config := cloudTestProviderConfig(server, "test-api-key", "Default") + fmt.Sprintf(cloudMockCertificateConfig, cn, 168)
+
+	var serial string
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate.cloud", "id", "certificate-1"),
+					r.TestCheckResourceAttr("venafi_certificate.cloud", "certificate_dn", "request-1"),
+					func(s *terraform.State) (err error) {
+						m.Lock()
+						defer m.Unlock()
+						serial, err = checkMockCertificate(s, "venafi_certificate.cloud", m.mockCA, cn)
+						return err
+					},
+				),
+			},
+			r.TestStep{
+				Config: config,
+				Check: func(s *terraform.State) error {
+					m.Lock()
+					defer m.Unlock()
+					got, err := checkMockCertificate(s, "venafi_certificate.cloud", m.mockCA, cn)
+					if err != nil {
+						return err
+					}
+					if got != serial || m.requests != 1 {
+						return fmt.Errorf("certificate %s was replaced by %s without reason", serial, got)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestCloudMockCertificateIDLookup(t *testing.T) {
+	m := newCloudCertificateMock(t, 24*90)
+	m.hideCertificateIDs = true
+	server, closeServer := newCloudTestServer(t, m)
+	defer closeServer()
+	config := cloudTestProviderConfig(server, "test-api-key", "Default") + fmt.Sprintf(cloudMockCertificateConfig, "lookup.venafi.example", 168)
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				//the certificate is kept under the request ID when its certificate ID isn't known yet
+				Config: config,
+				Check:  r.TestCheckResourceAttr("venafi_certificate.cloud", "id", "request-1"),
+			},
+			r.TestStep{
+				PreConfig: func() {
+					m.Lock()
+					defer m.Unlock()
+					m.hideCertificateIDs = false
+				},
+				Config: config,
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_certificate.cloud", "id", "certificate-1"),
+					r.TestCheckResourceAttr("venafi_certificate.cloud", "certificate_dn", "request-1"),
+					func(s *terraform.State) error {
+						m.Lock()
+						defer m.Unlock()
+						if m.requests != 1 {
+							return fmt.Errorf("certificate was requested again, %d requests", m.requests)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestCloudMockCertificateRenewal(t *testing.T) {
+	m := newCloudCertificateMock(t, 200)
+	server, closeServer := newCloudTestServer(t, m)
+	defer closeServer()
+	cn := "renewal.venafi.example"
+	config := cloudTestProviderConfig(server, "test-api-key", "Default") + fmt.Sprintf(cloudMockCertificateConfig, cn, 190)
+
+	var id string
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+				Check: func(s *terraform.State) error {
+					id = s.RootModule().Resources["venafi_certificate.cloud"].Primary.ID
+					return nil
+				},
+			},
+			r.TestStep{
+				//Venafi Cloud renews with a new certificate request
+				Config: config,
+				Check: func(s *terraform.State) error {
+					m.Lock()
+					defer m.Unlock()
+					if _, err := checkMockCertificate(s, "venafi_certificate.cloud", m.mockCA, cn); err != nil {
+						return err
+					}
+					if got := s.RootModule().Resources["venafi_certificate.cloud"].Primary.ID; got == id || m.requests < 2 {
+						return fmt.Errorf("certificate %s wasn't renewed, %d requests", id, m.requests)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestCloudMockCertificateErrors(t *testing.T) {
+	m := newCloudCertificateMock(t, 24*90)
+	m.failCN = "failed.venafi.example"
+	server, closeServer := newCloudTestServer(t, m)
+	defer closeServer()
+	provider := cloudTestProviderConfig(server, "test-api-key", "Default")
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config:      provider + fmt.Sprintf(cloudMockCertificateConfig, "failed.venafi.example", 168),
+				ExpectError: regexp.MustCompile(`(?s)Failed to retrieve certificate. Status:.*FAILED.*pickup ID: request-1`),
+			},
+			r.TestStep{
+				Config:      provider + fmt.Sprintf(cloudMockCertificateConfig, "web.example.com", 168),
+				ExpectError: regexp.MustCompile("The requested CN does not match any of the allowed CN regular expressions"),
+			},
+			r.TestStep{
+				Config: provider + `
+resource "venafi_certificate" "cloud" {
+  common_name = "revoked.venafi.example"
+  revoke_on_destroy = true
+}`,
+				ExpectError: regexp.MustCompile("revoke_on_destroy is not supported by Venafi Cloud"),
+			},
+			r.TestStep{
+				Config:      cloudTestProviderConfig(server, "test-api-key", "Missing") + fmt.Sprintf(cloudMockCertificateConfig, "zone.venafi.example", 168),
+				ExpectError: regexp.MustCompile("Unable to find zone with tag Missing"),
+			},
+			r.TestStep{
+				Config:      cloudTestProviderConfig(server, "wrong-api-key", "Default") + fmt.Sprintf(cloudMockCertificateConfig, "unauthorized.venafi.example", 168),
+				ExpectError: regexp.MustCompile(`(?s)Status: 401 Unauthorized\s+Error Code: 10501 Error: Invalid API key.*attribute: api_key.*HTTP status: 401 Unauthorized`),
+			},
+		},
+	})
+}
@@ -0,0 +1,344 @@
+package venafi
+
+import (
+	"crypto"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/certificate"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"log"
+	"net"
+	"sort"
+)
+
+func resourceVenafiCSR() *schema.Resource {
+	stringList := func(description string, computed bool) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeList,
+			Optional:    true,
+			Computed:    computed,
+			ForceNew:    true,
+			Description: description,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		}
+	}
+	subjectField := func(description string) *schema.Schema {
+		return &schema.Schema{
+			Type:        schema.TypeString,
+			Optional:    true,
+			Computed:    true,
+			ForceNew:    true,
+			Description: description,
+		}
+	}
+	return &schema.Resource{
+		Create: resourceVenafiCSRCreate,
+		Read:   resourceVenafiCSRRead,
+		Delete: resourceVenafiCSRDelete,
+
+		CustomizeDiff: resourceVenafiCSRCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"zone": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Zone whose defaults and policy are applied to the request. Provider zone is used when empty.",
+			},
+			"common_name": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Common name of the request",
+			},
+			"san_dns":             stringList("List of DNS names to use as subjects of the request", false),
+			"san_email":           stringList("List of email addresses to use as subjects of the request", false),
+			"san_ip":              stringList("List of IP addresses to use as subjects of the request", false),
+			"organization":        subjectField("Organization of the subject. Zone default is used when empty."),
+			"organizational_unit": stringList("Organizational units of the subject. Zone defaults are used when empty.", true),
+			"country":             subjectField("Country of the subject. Zone default is used when empty."),
+			"province":            subjectField("State or province of the subject. Zone default is used when empty."),
+			"locality":            subjectField("Locality of the subject. Zone default is used when empty."),
+			"algorithm": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				Description:  "Key algorithm: RSA, ECDSA or ED25519. First key type allowed by the zone is used when empty.",
+				ValidateFunc: validateKeyAlgorithm,
+			},
+			"rsa_bits": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				Description:  "Number of bits of the RSA key. Smallest size allowed by the zone is used when empty.",
+				ValidateFunc: validateRSAKeySize,
+			},
+			"ecdsa_curve": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				Description:  "ECDSA curve of the key. First curve allowed by the zone is used when empty.",
+				ValidateFunc: validateECDSACurve,
+			},
+			"allow_weak_keys": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     false,
+				Description: "Allow RSA keys shorter than 2048 bits and the P224 curve",
+			},
+			"key_password": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Sensitive:   true,
+				Description: "Private key password.",
+			},
+			"private_key_format": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      privateKeyFormatPKCS1,
+				Description:  "Format of private_key_pem: pkcs1, pkcs8 or pkcs8-encrypted. pkcs8-encrypted requires key_password.",
+				ValidateFunc: validatePrivateKeyFormat,
+			},
+			"csr_pem": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "PEM encoded certificate signing request",
+			},
+			"private_key_pem": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "PEM encoded private key of the request",
+			},
+			"private_key_fingerprint": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "SHA-256 fingerprint of the public key",
+			},
+		},
+	}
+}
+
+func resourceVenafiCSRCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if keyChanged(d, "private_key_format", "key_password") {
+		if err := checkPrivateKeyFormat(d.Get("private_key_format").(string), d.Get("key_password").(string)); err != nil {
+			return err
+		}
+	}
+	if !keyChanged(d, "algorithm", "rsa_bits", "ecdsa_curve", "allow_weak_keys") {
+		return nil
+	}
+	//Key parameters left empty are picked from the zone and checked against it on create
+	rsaBits := minimalRSAKeySize
+	if v, ok := d.GetOk("rsa_bits"); ok {
+		rsaBits = v.(int)
+	}
+	return checkKeyStrength(d.Get("algorithm").(string), rsaBits, d.Get("ecdsa_curve").(string), d.Get("allow_weak_keys").(bool))
+}
+
+func resourceVenafiCSRCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	zone := d.Get("zone").(string)
+	if zone == "" {
+		zone = config.vcert.Zone
+	}
+	cl, err := config.newConnector()
+	if err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] Reading configuration of zone %s", zone)
+	zoneConfig, err := cl.ReadZoneConfiguration(zone)
+	if err != nil {
+		return newDiagnostic(config.withZone(zone), "error reading zone configuration", err)
+	}
+
+	req := &certificate.Request{}
+	req.Subject.CommonName = d.Get("common_name").(string)
+	for _, v := range d.Get("san_dns").([]interface{}) {
+		req.DNSNames = append(req.DNSNames, v.(string))
+	}
+	if !sliceContains(req.DNSNames, req.Subject.CommonName) {
+		log.Printf("[DEBUG] Adding CN %s to SAN because it wasn't included.", req.Subject.CommonName)
+		req.DNSNames = append(req.DNSNames, req.Subject.CommonName)
+	}
+	for _, v := range d.Get("san_email").([]interface{}) {
+		req.EmailAddresses = append(req.EmailAddresses, v.(string))
+	}
+	for _, v := range d.Get("san_ip").([]interface{}) {
+		ip := net.ParseIP(v.(string))
+		if ip == nil {
+			return fmt.Errorf("invalid IP address %#v", v)
+		}
+		req.IPAddresses = append(req.IPAddresses, ip)
+	}
+	applySubjectDefaults(d, req, zoneConfig)
+
+	algorithm, err := applyKeyDefaults(d, req, zoneConfig.AllowedKeyConfigurations)
+	if err != nil {
+		return err
+	}
+	if err = checkKeyStrength(algorithm, req.KeyLength, req.KeyCurve.String(), d.Get("allow_weak_keys").(bool)); err != nil {
+		return err
+	}
+	policyCheck := *zoneConfig
+	if algorithm == algorithmED25519 {
+		//vcert knows nothing about Ed25519 keys so only the subject is checked
+		policyCheck.AllowedKeyConfigurations = nil
+	}
+	if err = policyCheck.ValidateCertificateRequest(req); err != nil {
+		diagnostic := newDiagnostic(config.withZone(zone), fmt.Sprintf("request doesn't comply with policy of zone %s", zone), err)
+		diagnostic.attribute = ""
+		diagnostic.hint = "change the request to match the zone policy shown by the venafi_zone data source"
+		return diagnostic
+	}
+
+	var pk interface{}
+	switch algorithm {
+	case algorithmED25519:
+		pk, err = generateED25519PrivateKey()
+	case algorithmECDSA:
+		pk, err = certificate.GenerateECDSAPrivateKey(req.KeyCurve)
+	default:
+		pk, err = certificate.GenerateRSAPrivateKey(req.KeyLength)
+	}
+	if err != nil {
+		return fmt.Errorf("error generating key: %s", err)
+	}
+	if err = generateUserCSR(req, pk); err != nil {
+		return fmt.Errorf("error generating CSR: %s", err)
+	}
+	keyPEM, err := encodePrivateKey(pk, d.Get("key_password").(string), d.Get("private_key_format").(string))
+	if err != nil {
+		return err
+	}
+	fingerprint, err := publicKeyFingerprint(pk.(crypto.Signer).Public())
+	if err != nil {
+		return fmt.Errorf("error calculating public key fingerprint: %s", err)
+	}
+
+	attributes := map[string]interface{}{
+		"csr_pem":                 string(req.CSR),
+		"private_key_pem":         keyPEM,
+		"private_key_fingerprint": fingerprint,
+	}
+	for k, v := range attributes {
+		if err = d.Set(k, v); err != nil {
+			return fmt.Errorf("error setting %s: %s", k, err)
+		}
+	}
+	d.SetId(fingerprint)
+	return nil
+}
+
+// applySubjectDefaults fills subject fields which are not configured from the zone and records the result.
+func applySubjectDefaults(d *schema.ResourceData, req *certificate.Request, zoneConfig *endpoint.ZoneConfiguration) {
+	value := func(key string, zoneValue string) []string {
+		if v := d.Get(key).(string); v != "" {
+			return []string{v}
+		}
+		if zoneValue != "" {
+			d.Set(key, zoneValue)
+			return []string{zoneValue}
+		}
+		return nil
+	}
+	req.Subject.Organization = value("organization", zoneConfig.Organization)
+	req.Subject.Country = value("country", zoneConfig.Country)
+	req.Subject.Province = value("province", zoneConfig.Province)
+	req.Subject.Locality = value("locality", zoneConfig.Locality)
+	if units := d.Get("organizational_unit").([]interface{}); len(units) > 0 {
+		for _, u := range units {
+			req.Subject.OrganizationalUnit = append(req.Subject.OrganizationalUnit, u.(string))
+		}
+	} else {
+		req.Subject.OrganizationalUnit = zoneConfig.OrganizationalUnit
+		d.Set("organizational_unit", zoneConfig.OrganizationalUnit)
+	}
+}
+
+// applyKeyDefaults sets the key parameters of the request, picking the ones which are not configured
+// from the first allowed key configuration of the zone.
+func applyKeyDefaults(d *schema.ResourceData, req *certificate.Request, allowed []endpoint.AllowedKeyConfiguration) (string, error) {
+	algorithm := d.Get("algorithm").(string)
+	var keyConfig *endpoint.AllowedKeyConfiguration
+	for i, c := range allowed {
+		if algorithm == "" || algorithm == c.KeyType.String() {
+			keyConfig = &allowed[i]
+			break
+		}
+	}
+	if algorithm == "" {
+		algorithm = algorithmRSA
+		if keyConfig != nil {
+			algorithm = keyConfig.KeyType.String()
+		}
+	}
+
+	switch algorithm {
+	case algorithmRSA:
+		req.KeyType = certificate.KeyTypeRSA
+		req.KeyLength = d.Get("rsa_bits").(int)
+		if req.KeyLength == 0 {
+			req.KeyLength = minimalRSAKeySize
+			if keyConfig != nil && len(keyConfig.KeySizes) > 0 {
+				req.KeyLength = preferredRSAKeySize(keyConfig.KeySizes)
+			}
+		}
+		if err := d.Set("rsa_bits", req.KeyLength); err != nil {
+			return "", fmt.Errorf("error setting rsa_bits: %s", err)
+		}
+	case algorithmECDSA:
+		req.KeyType = certificate.KeyTypeECDSA
+		curve := d.Get("ecdsa_curve").(string)
+		if curve == "" {
+			curve = "P521"
+			if keyConfig != nil && len(keyConfig.KeyCurves) > 0 {
+				curve = keyConfig.KeyCurves[0].String()
+			}
+		}
+		req.KeyCurve.Set(curve)
+		if err := d.Set("ecdsa_curve", curve); err != nil {
+			return "", fmt.Errorf("error setting ecdsa_curve: %s", err)
+		}
+		if keyConfig != nil && len(keyConfig.KeyCurves) > 0 {
+			found := false
+			for _, c := range keyConfig.KeyCurves {
+				found = found || c == req.KeyCurve
+			}
+			if !found {
+				return "", fmt.Errorf("ECDSA curve %s is not allowed by the zone", curve)
+			}
+		}
+	}
+	if err := d.Set("algorithm", algorithm); err != nil {
+		return "", fmt.Errorf("error setting algorithm: %s", err)
+	}
+	return algorithm, nil
+}
+
+// preferredRSAKeySize returns the smallest allowed size which isn't weak, or the largest one if all of them are.
+func preferredRSAKeySize(sizes []int) int {
+	sorted := append([]int{}, sizes...)
+	sort.Ints(sorted)
+	for _, s := range sorted {
+		if s >= minimalRSAKeySize {
+			return s
+		}
+	}
+	return sorted[len(sorted)-1]
+}
+
+func resourceVenafiCSRRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+func resourceVenafiCSRDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
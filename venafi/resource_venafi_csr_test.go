@@ -0,0 +1,193 @@
+package venafi
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// checkCSR parses csr_pem and verifies that it is signed by private_key_pem
+func checkCSR(attrs map[string]string) (*x509.CertificateRequest, error) {
+	csr, err := parseCSR(attrs["csr_pem"])
+	if err != nil {
+		return nil, err
+	}
+	pk, err := parsePrivateKey([]byte(attrs["private_key_pem"]))
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(pk.(crypto.Signer).Public(), csr.PublicKey) {
+		return nil, fmt.Errorf("private key doesn't match request")
+	}
+	return csr, nil
+}
+
+const devCSRResource = `
+provider "venafi" {
+  alias = "dev"
+  dev_mode = true
+}
+resource "venafi_csr" "dev" {
+  provider = "venafi.dev"
+  common_name = "web.venafi.example"
+  san_dns = ["www.venafi.example"]
+  san_ip = ["10.0.0.1"]
+  organization = "Venafi"
+  algorithm = "ECDSA"
+  ecdsa_curve = "P256"
+}`
+
+func TestDevCSR(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: devCSRResource,
+				Check: func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["venafi_csr.dev"].Primary.Attributes
+					csr, err := checkCSR(attrs)
+					if err != nil {
+						return err
+					}
+					if csr.Subject.CommonName != "web.venafi.example" || !reflect.DeepEqual(csr.Subject.Organization, []string{"Venafi"}) {
+						return fmt.Errorf("unexpected subject %s", csr.Subject)
+					}
+					if !sameStringSlice(csr.DNSNames, []string{"www.venafi.example", "web.venafi.example"}) || len(csr.IPAddresses) != 1 {
+						return fmt.Errorf("unexpected SAN %s %s", csr.DNSNames, csr.IPAddresses)
+					}
+					if csr.PublicKeyAlgorithm != x509.ECDSA {
+						return fmt.Errorf("expected ECDSA key, got %s", csr.PublicKeyAlgorithm)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+const devCSRCertificateResource = `
+provider "venafi" {
+  alias = "dev"
+  dev_mode = true
+}
+resource "venafi_csr" "dev" {
+  provider = "venafi.dev"
+  common_name = "web.venafi.example"
+  san_dns = ["www.venafi.example"]
+}
+resource "venafi_certificate" "dev" {
+  provider = "venafi.dev"
+  common_name = "%s"
+  csr_pem = "${venafi_csr.dev.csr_pem}"
+}`
+
+func TestDevCertificateFromCSR(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: fmt.Sprintf(devCSRCertificateResource, "web.venafi.example"),
+				Check: func(s *terraform.State) error {
+					csrAttrs := s.RootModule().Resources["venafi_csr.dev"].Primary.Attributes
+					attrs := s.RootModule().Resources["venafi_certificate.dev"].Primary.Attributes
+					if attrs["private_key_pem"] != "" {
+						return fmt.Errorf("private key should not be known to the certificate resource")
+					}
+					if attrs["private_key_fingerprint"] != csrAttrs["private_key_fingerprint"] {
+						return fmt.Errorf("certificate key %s doesn't match CSR key %s", attrs["private_key_fingerprint"], csrAttrs["private_key_fingerprint"])
+					}
+					block, _ := pem.Decode([]byte(attrs["certificate"]))
+					cert, err := x509.ParseCertificate(block.Bytes)
+					if err != nil {
+						return err
+					}
+					if !sameStringSlice(cert.DNSNames, []string{"www.venafi.example", "web.venafi.example"}) {
+						return fmt.Errorf("unexpected SAN %s", cert.DNSNames)
+					}
+					return nil
+				},
+			},
+			r.TestStep{
+				Config:      fmt.Sprintf(devCSRCertificateResource, "other.venafi.example"),
+				ExpectError: regexp.MustCompile("doesn't match common name web.venafi.example of the CSR"),
+			},
+		},
+	})
+}
+
+// tppCheckPolicyHandlers returns a Venafi Platform zone with locked subject and key defaults
+func tppCheckPolicyHandlers() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"/vedsdk/certificates/checkpolicy": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"Policy": {
+  "KeyPair": {"KeyAlgorithm": {"Locked": true, "Value": "RSA"}, "KeySize": {"Locked": true, "Value": 3072}},
+  "Subject": {
+    "City": {"Locked": false, "Value": "Salt Lake City"},
+    "Country": {"Locked": false, "Value": "US"},
+    "Organization": {"Locked": true, "Value": "Venafi"},
+    "OrganizationalUnit": {"Locked": false, "Values": ["Engineering"]},
+    "State": {"Locked": false, "Value": "Utah"}
+  },
+  "SubjAltNameDnsAllowed": true,
+  "WhitelistedDomains": ["venafi.example"],
+  "WildcardsAllowed": true
+}}`)
+		},
+	}
+}
+
+const tppCSRResource = `
+resource "venafi_csr" "tpp" {
+  common_name = "web.venafi.example"
+  locality = "Provo"
+  %s
+}`
+
+func TestTPPCSRZoneDefaults(t *testing.T) {
+	server := newTPPTestServer(t, tppCheckPolicyHandlers())
+	defer server.Close()
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + fmt.Sprintf(tppCSRResource, ""),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_csr.tpp", "algorithm", "RSA"),
+					r.TestCheckResourceAttr("venafi_csr.tpp", "rsa_bits", "4096"),
+					r.TestCheckResourceAttr("venafi_csr.tpp", "organization", "Venafi"),
+					func(s *terraform.State) error {
+						csr, err := checkCSR(s.RootModule().Resources["venafi_csr.tpp"].Primary.Attributes)
+						if err != nil {
+							return err
+						}
+						subject := csr.Subject
+						if !reflect.DeepEqual(subject.Organization, []string{"Venafi"}) ||
+							!reflect.DeepEqual(subject.OrganizationalUnit, []string{"Engineering"}) ||
+							!reflect.DeepEqual(subject.Locality, []string{"Provo"}) ||
+							!reflect.DeepEqual(subject.Province, []string{"Utah"}) ||
+							!reflect.DeepEqual(subject.Country, []string{"US"}) {
+							return fmt.Errorf("unexpected subject %s", subject)
+						}
+						return nil
+					},
+				),
+			},
+			r.TestStep{
+				Config:      tppTestProviderConfig(server) + fmt.Sprintf(tppCSRResource, `organization = "Example"`),
+				ExpectError: regexp.MustCompile(`doesn't comply with policy of zone devops(.|\n)*backend: Venafi Platform at (.|\n)*suggestion: change the request`),
+			},
+			r.TestStep{
+				Config:      tppTestProviderConfig(server) + fmt.Sprintf(tppCSRResource, `rsa_bits = 2048`),
+				ExpectError: regexp.MustCompile("doesn't comply with policy of zone devops"),
+			},
+		},
+	})
+}
@@ -0,0 +1,486 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	tppClassPolicy          = "Policy"
+	tppClassX509Certificate = "X509 Certificate"
+
+	//WebSDK Config methods return HTTP 200 with result code, 1 is success, 102 is attribute not set and 400 is object
+	//doesn't exist
+	tppConfigResultSuccess           = 1
+	tppConfigResultAttributeNotFound = 102
+	tppConfigResultObjectAbsent      = 400
+)
+
+// policySubjectFields maps subject attributes of venafi_policy to Venafi Platform policy attribute names
+var policySubjectFields = []struct {
+	key       string
+	attribute string
+}{
+	{"organization", "Organization"},
+	{"organizational_units", "Organizational Unit"},
+	{"city", "City"},
+	{"state", "State"},
+	{"country", "Country"},
+}
+
+func resourceVenafiPolicy() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceVenafiPolicyCreate,
+		Read:   resourceVenafiPolicyRead,
+		Update: resourceVenafiPolicyUpdate,
+		Delete: resourceVenafiPolicyDelete,
+
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the policy folder",
+			},
+			"parent_dn": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     tppPolicyRoot,
+				Description: "DN of the parent policy folder",
+			},
+			"policy_dn": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "DN of the policy folder which can be used as zone",
+			},
+			"organization": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"organizational_units": &schema.Schema{
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"city": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"state": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"country": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"locked_subject_fields": &schema.Schema{
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "Subject fields which requests can't override: organization, organizational_units, city, state or country",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validatePolicySubjectField,
+				},
+			},
+			"key_algorithm": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "Key algorithm, RSA or ECDSA",
+				ValidateFunc: validatePolicyKeyAlgorithm,
+			},
+			"key_size": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Description: "RSA key size",
+			},
+			"ecdsa_curve": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "ECDSA curve, P256, P384 or P521",
+				ValidateFunc: validatePolicyECDSACurve,
+			},
+			"key_locked": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Only allow the configured key algorithm, size and curve",
+			},
+			"allow_wildcards": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+			"allow_key_reuse": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"domain_whitelist": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Domain suffixes allowed in common names and DNS names",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"force_destroy": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Delete the policy folder with all certificates and folders in it",
+			},
+		},
+	}
+}
+
+func validatePolicySubjectField(v interface{}, k string) (ws []string, errs []error) {
+	for _, f := range policySubjectFields {
+		if f.key == v.(string) {
+			return
+		}
+	}
+	errs = append(errs, fmt.Errorf("%s must be one of organization, organizational_units, city, state or country, got %s", k, v))
+	return
+}
+
+// validatePolicyECDSACurve accepts the curves of the Elliptic Curve policy attribute, which has no P224
+func validatePolicyECDSACurve(v interface{}, k string) (ws []string, errs []error) {
+	switch v.(string) {
+	case "P256", "P384", "P521":
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of P256, P384 or P521, got %s", k, v))
+	}
+	return
+}
+
+func validatePolicyKeyAlgorithm(v interface{}, k string) (ws []string, errs []error) {
+	switch v.(string) {
+	case algorithmRSA, algorithmECDSA:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be %s or %s, got %s", k, algorithmRSA, algorithmECDSA, v))
+	}
+	return
+}
+
+// tppPolicyClient returns WebSDK client, policies are managed only by Venafi Platform
+func tppPolicyClient(meta interface{}) (*restClient, error) {
+	config := meta.(*providerConfig)
+	if config.vcert.ConnectorType != endpoint.ConnectorTypeTPP {
+		diagnostic := newDiagnostic(config, "venafi_policy is supported only by Venafi Platform", nil)
+		diagnostic.hint = "configure the provider with url, tpp_username and tpp_password"
+		return nil, diagnostic
+	}
+	c, err := newRestClient(config.stopContext, config)
+	if err != nil {
+		return nil, newDiagnostic(config, "error connecting to Venafi", err)
+	}
+	return c, nil
+}
+
+type tppConfigResponse struct {
+	Result    int      `json:"Result"`
+	Error     string   `json:"Error"`
+	Locked    bool     `json:"Locked"`
+	Values    []string `json:"Values"`
+	GUID      string   `json:"GUID"`
+	ClassName string   `json:"ClassName"`
+}
+
+// tppConfig calls a WebSDK Config method and checks its result code
+func tppConfig(c *restClient, method string, data map[string]interface{}) (*tppConfigResponse, error) {
+	var res tppConfigResponse
+	err := c.request("POST", "Config/"+method, data, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Result != tppConfigResultSuccess {
+		if res.Error == "" {
+			res.Error = "result code " + strconv.Itoa(res.Result)
+		}
+		return &res, fmt.Errorf("Config/%s failed for %s: %s", method, data["ObjectDN"], res.Error)
+	}
+	return &res, nil
+}
+
+func writePolicyAttribute(c *restClient, dn string, attribute string, values []string, locked bool) error {
+	if len(values) == 0 {
+		log.Printf("[DEBUG] Clearing policy attribute %s of %s", attribute, dn)
+		for _, l := range []bool{true, false} {
+			res, err := tppConfig(c, "ClearPolicyAttribute", map[string]interface{}{
+				"ObjectDN": dn, "Class": tppClassX509Certificate, "AttributeName": attribute, "Locked": l,
+			})
+			//clearing an attribute which isn't set isn't an error for us
+			if err != nil && (res == nil || res.Result != tppConfigResultAttributeNotFound) {
+				return err
+			}
+		}
+		return nil
+	}
+	log.Printf("[DEBUG] Setting policy attribute %s of %s to %v, locked: %t", attribute, dn, values, locked)
+	_, err := tppConfig(c, "WritePolicy", map[string]interface{}{
+		"ObjectDN": dn, "Class": tppClassX509Certificate, "AttributeName": attribute, "Locked": locked, "Values": values,
+	})
+	return err
+}
+
+func readPolicyAttribute(c *restClient, dn string, attribute string) ([]string, bool, error) {
+	res, err := tppConfig(c, "ReadPolicy", map[string]interface{}{
+		"ObjectDN": dn, "Class": tppClassX509Certificate, "AttributeName": attribute,
+	})
+	if err != nil && res != nil && res.Result == tppConfigResultAttributeNotFound {
+		//attribute is not set on the folder
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return res.Values, res.Locked, nil
+}
+
+func resourceVenafiPolicyCreate(d *schema.ResourceData, meta interface{}) error {
+	c, err := tppPolicyClient(meta)
+	if err != nil {
+		return err
+	}
+	dn := tppPolicyDN(d.Get("parent_dn").(string)) + "\\" + d.Get("name").(string)
+	log.Printf("[INFO] Creating policy folder %s", dn)
+	_, err = tppConfig(c, "Create", map[string]interface{}{"ObjectDN": dn, "Class": tppClassPolicy})
+	if err != nil {
+		diagnostic := newDiagnostic(meta.(*providerConfig), "error creating policy folder", err)
+		diagnostic.attribute = "parent_dn"
+		return diagnostic
+	}
+	d.SetId(dn)
+	err = writePolicySettings(c, d, true)
+	if err != nil {
+		return newDiagnostic(meta.(*providerConfig), fmt.Sprintf("error writing policy of %s", dn), err)
+	}
+	return resourceVenafiPolicyRead(d, meta)
+}
+
+func resourceVenafiPolicyUpdate(d *schema.ResourceData, meta interface{}) error {
+	c, err := tppPolicyClient(meta)
+	if err != nil {
+		return err
+	}
+	err = writePolicySettings(c, d, false)
+	if err != nil {
+		return newDiagnostic(meta.(*providerConfig), fmt.Sprintf("error writing policy of %s", d.Id()), err)
+	}
+	return resourceVenafiPolicyRead(d, meta)
+}
+
+// writePolicySettings writes policy attributes which are new or changed
+func writePolicySettings(c *restClient, d *schema.ResourceData, all bool) error {
+	dn := d.Id()
+	write := func(attribute string, values []string, locked bool) error {
+		//new policy folder has no attributes to clear
+		if all && len(values) == 0 {
+			return nil
+		}
+		return writePolicyAttribute(c, dn, attribute, values, locked)
+	}
+	locked := d.Get("locked_subject_fields").(*schema.Set)
+	for _, f := range policySubjectFields {
+		if !all && !d.HasChange(f.key) && !d.HasChange("locked_subject_fields") {
+			continue
+		}
+		var values []string
+		switch v := d.Get(f.key).(type) {
+		case string:
+			if v != "" {
+				values = []string{v}
+			}
+		case []interface{}:
+			values = toStringSlice(v)
+		}
+		err := write(f.attribute, values, locked.Contains(f.key))
+		if err != nil {
+			return err
+		}
+	}
+
+	if all || d.HasChange("key_algorithm") || d.HasChange("key_size") || d.HasChange("ecdsa_curve") || d.HasChange("key_locked") {
+		keyLocked := d.Get("key_locked").(bool)
+		var algorithm, size, curve []string
+		switch d.Get("key_algorithm").(string) {
+		case algorithmRSA:
+			algorithm = []string{"RSA"}
+		case algorithmECDSA:
+			algorithm = []string{"ECC"}
+		}
+		if v := d.Get("key_size").(int); v != 0 {
+			size = []string{strconv.Itoa(v)}
+		}
+		if v := d.Get("ecdsa_curve").(string); v != "" {
+			curve = []string{v}
+		}
+		for attribute, values := range map[string][]string{"Key Algorithm": algorithm, "Key Bit Strength": size, "Elliptic Curve": curve} {
+			err := write(attribute, values, keyLocked)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if all || d.HasChange("allow_wildcards") {
+		err := write("Prohibit Wildcard", []string{boolToTPP(!d.Get("allow_wildcards").(bool))}, true)
+		if err != nil {
+			return err
+		}
+	}
+	if all || d.HasChange("allow_key_reuse") {
+		err := write("Allow Private Key Reuse", []string{boolToTPP(d.Get("allow_key_reuse").(bool))}, true)
+		if err != nil {
+			return err
+		}
+	}
+	if all || d.HasChange("domain_whitelist") {
+		err := write("Domain Suffix Whitelist", toStringSlice(d.Get("domain_whitelist").([]interface{})), true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resourceVenafiPolicyRead(d *schema.ResourceData, meta interface{}) error {
+	c, err := tppPolicyClient(meta)
+	if err != nil {
+		return err
+	}
+	dn := d.Id()
+	res, err := tppConfig(c, "IsValid", map[string]interface{}{"ObjectDN": dn})
+	if res != nil && res.Result == tppConfigResultObjectAbsent {
+		log.Printf("[WARN] Policy folder %s doesn't exist anymore", dn)
+		d.SetId("")
+		return nil
+	} else if err != nil {
+		return newDiagnostic(meta.(*providerConfig), fmt.Sprintf("error reading policy folder %s", dn), err)
+	}
+	if err = d.Set("policy_dn", dn); err != nil {
+		return err
+	}
+	err = readPolicySettings(c, d)
+	if err != nil {
+		return newDiagnostic(meta.(*providerConfig), fmt.Sprintf("error reading policy of %s", dn), err)
+	}
+	return nil
+}
+
+// readPolicySettings reads policy attributes of the policy folder into d
+func readPolicySettings(c *restClient, d *schema.ResourceData) error {
+	dn := d.Id()
+
+	locked := []interface{}{}
+	for _, f := range policySubjectFields {
+		values, l, err := readPolicyAttribute(c, dn, f.attribute)
+		if err != nil {
+			return err
+		}
+		if l && len(values) > 0 {
+			locked = append(locked, f.key)
+		}
+		if f.key == "organizational_units" {
+			err = d.Set(f.key, values)
+		} else {
+			err = d.Set(f.key, strings.Join(values, ""))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := d.Set("locked_subject_fields", locked); err != nil {
+		return err
+	}
+
+	algorithm, keyLocked, err := readPolicyAttribute(c, dn, "Key Algorithm")
+	if err != nil {
+		return err
+	}
+	switch strings.Join(algorithm, "") {
+	case "RSA":
+		err = d.Set("key_algorithm", algorithmRSA)
+	case "ECC":
+		err = d.Set("key_algorithm", algorithmECDSA)
+	default:
+		err = d.Set("key_algorithm", "")
+	}
+	if err != nil {
+		return err
+	}
+	size, _, err := readPolicyAttribute(c, dn, "Key Bit Strength")
+	if err != nil {
+		return err
+	}
+	keySize := 0
+	if len(size) > 0 {
+		keySize, _ = strconv.Atoi(size[0])
+	}
+	curve, _, err := readPolicyAttribute(c, dn, "Elliptic Curve")
+	if err != nil {
+		return err
+	}
+	prohibitWildcard, _, err := readPolicyAttribute(c, dn, "Prohibit Wildcard")
+	if err != nil {
+		return err
+	}
+	keyReuse, _, err := readPolicyAttribute(c, dn, "Allow Private Key Reuse")
+	if err != nil {
+		return err
+	}
+	whitelist, _, err := readPolicyAttribute(c, dn, "Domain Suffix Whitelist")
+	if err != nil {
+		return err
+	}
+
+	attributes := map[string]interface{}{
+		"key_size":         keySize,
+		"ecdsa_curve":      strings.Join(curve, ""),
+		"key_locked":       keyLocked && (len(algorithm) > 0 || keySize != 0 || len(curve) > 0),
+		"allow_wildcards":  strings.Join(prohibitWildcard, "") != "1",
+		"allow_key_reuse":  strings.Join(keyReuse, "") == "1",
+		"domain_whitelist": whitelist,
+	}
+	for k, v := range attributes {
+		if err = d.Set(k, v); err != nil {
+			return fmt.Errorf("error setting %s: %s", k, err)
+		}
+	}
+	return nil
+}
+
+func resourceVenafiPolicyDelete(d *schema.ResourceData, meta interface{}) error {
+	c, err := tppPolicyClient(meta)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Deleting policy folder %s", d.Id())
+	_, err = tppConfig(c, "Delete", map[string]interface{}{"ObjectDN": d.Id(), "Recursive": d.Get("force_destroy").(bool)})
+	if err != nil {
+		diagnostic := newDiagnostic(meta.(*providerConfig), fmt.Sprintf("error deleting policy folder %s", d.Id()), err)
+		diagnostic.attribute = "force_destroy"
+		diagnostic.hint = "set force_destroy to delete a policy folder which isn't empty"
+		return diagnostic
+	}
+	d.SetId("")
+	return nil
+}
+
+func boolToTPP(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func toStringSlice(list []interface{}) []string {
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		result = append(result, v.(string))
+	}
+	return result
+}
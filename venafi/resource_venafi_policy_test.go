@@ -0,0 +1,204 @@
+package venafi
+
+import (
+	"encoding/json"
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type tppPolicyValue struct {
+	Values []string
+	Locked bool
+}
+
+// tppPolicyStore keeps policy folders of the WebSDK Config mock
+type tppPolicyStore struct {
+	sync.Mutex
+	objects    map[string]bool
+	attributes map[string]tppPolicyValue
+	//readError fails ReadPolicy like missing permissions
+	readError string
+}
+
+func (s *tppPolicyStore) handlers(t *testing.T) map[string]http.HandlerFunc {
+	config := func(handle func(req map[string]interface{}) interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&req)
+			s.Lock()
+			defer s.Unlock()
+			json.NewEncoder(w).Encode(handle(req))
+		}
+	}
+	key := func(req map[string]interface{}) string {
+		return fmt.Sprintf("%s|%s", req["ObjectDN"], req["AttributeName"])
+	}
+	return map[string]http.HandlerFunc{
+		"/vedsdk/Config/Create": config(func(req map[string]interface{}) interface{} {
+			if req["Class"] != tppClassPolicy {
+				t.Errorf("unexpected class %s", req["Class"])
+			}
+			s.objects[req["ObjectDN"].(string)] = true
+			return map[string]interface{}{"Result": 1}
+		}),
+		"/vedsdk/Config/IsValid": config(func(req map[string]interface{}) interface{} {
+			if !s.objects[req["ObjectDN"].(string)] {
+				return map[string]interface{}{"Result": 400, "Error": "Object does not exist"}
+			}
+			return map[string]interface{}{"Result": 1}
+		}),
+		"/vedsdk/Config/WritePolicy": config(func(req map[string]interface{}) interface{} {
+			values := []string{}
+			for _, v := range req["Values"].([]interface{}) {
+				values = append(values, v.(string))
+			}
+			s.attributes[key(req)] = tppPolicyValue{values, req["Locked"].(bool)}
+			return map[string]interface{}{"Result": 1}
+		}),
+		"/vedsdk/Config/ClearPolicyAttribute": config(func(req map[string]interface{}) interface{} {
+			if v, ok := s.attributes[key(req)]; !ok || v.Locked != req["Locked"].(bool) {
+				return map[string]interface{}{"Result": 102, "Error": "Attribute not found"}
+			}
+			delete(s.attributes, key(req))
+			return map[string]interface{}{"Result": 1}
+		}),
+		"/vedsdk/Config/ReadPolicy": config(func(req map[string]interface{}) interface{} {
+			if s.readError != "" {
+				return map[string]interface{}{"Result": 300, "Error": s.readError}
+			}
+			v, ok := s.attributes[key(req)]
+			if !ok {
+				return map[string]interface{}{"Result": 102, "Error": "Attribute not found"}
+			}
+			return map[string]interface{}{"Result": 1, "Values": v.Values, "Locked": v.Locked}
+		}),
+		"/vedsdk/Config/Delete": config(func(req map[string]interface{}) interface{} {
+			dn := req["ObjectDN"].(string)
+			if !req["Recursive"].(bool) {
+				t.Errorf("expected recursive delete of %s", dn)
+			}
+			delete(s.objects, dn)
+			for k := range s.attributes {
+				if strings.HasPrefix(k, dn+"|") {
+					delete(s.attributes, k)
+				}
+			}
+			return map[string]interface{}{"Result": 1}
+		}),
+	}
+}
+
+func (s *tppPolicyStore) check(dn string, expected map[string]tppPolicyValue) error {
+	s.Lock()
+	defer s.Unlock()
+	if !s.objects[dn] {
+		return fmt.Errorf("policy folder %s doesn't exist", dn)
+	}
+	for attribute, v := range expected {
+		got, ok := s.attributes[dn+"|"+attribute]
+		if v.Values == nil {
+			if ok {
+				return fmt.Errorf("attribute %s should not be set, got %v", attribute, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, v) {
+			return fmt.Errorf("attribute %s: expected %v, got %v", attribute, v, got)
+		}
+	}
+	return nil
+}
+
+const tppPolicyResource = `
+resource "venafi_policy" "devops" {
+  name = "team"
+  parent_dn = "devops"
+  organization = "%s"
+  organizational_units = ["Engineering", "Ops"]
+  locked_subject_fields = ["organization"]
+  key_algorithm = "RSA"
+  key_size = 2048
+  key_locked = true
+  allow_wildcards = false
+  domain_whitelist = [%s]
+  force_destroy = true
+}`
+
+func TestTPPPolicy(t *testing.T) {
+	store := &tppPolicyStore{objects: map[string]bool{}, attributes: map[string]tppPolicyValue{}}
+	server := newTPPTestServer(t, store.handlers(t))
+	defer server.Close()
+	dn := "\\VED\\Policy\\devops\\team"
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			if store.objects[dn] {
+				return fmt.Errorf("policy folder %s was not deleted", dn)
+			}
+			return nil
+		},
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + fmt.Sprintf(tppPolicyResource, "Venafi", `"venafi.example"`),
+				Check: r.ComposeTestCheckFunc(
+					r.TestCheckResourceAttr("venafi_policy.devops", "policy_dn", dn),
+					func(s *terraform.State) error {
+						return store.check(dn, map[string]tppPolicyValue{
+							"Organization":            {[]string{"Venafi"}, true},
+							"Organizational Unit":     {[]string{"Engineering", "Ops"}, false},
+							"City":                    {},
+							"Key Algorithm":           {[]string{"RSA"}, true},
+							"Key Bit Strength":        {[]string{"2048"}, true},
+							"Prohibit Wildcard":       {[]string{"1"}, true},
+							"Allow Private Key Reuse": {[]string{"0"}, true},
+							"Domain Suffix Whitelist": {[]string{"venafi.example"}, true},
+						})
+					},
+				),
+			},
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + fmt.Sprintf(tppPolicyResource, "Venafi Inc", ""),
+				Check: func(s *terraform.State) error {
+					return store.check(dn, map[string]tppPolicyValue{
+						"Organization":            {[]string{"Venafi Inc"}, true},
+						"Domain Suffix Whitelist": {},
+					})
+				},
+			},
+			r.TestStep{
+				Config:      tppTestProviderConfig(server) + strings.Replace(fmt.Sprintf(tppPolicyResource, "Venafi Inc", ""), "key_size = 2048", "key_size = 2048\n  ecdsa_curve = \"P512\"", 1),
+				ExpectError: regexp.MustCompile("ecdsa_curve must be one of P256, P384 or P521, got P512"),
+			},
+			r.TestStep{
+				Config:      tppTestProviderConfig(server) + strings.Replace(fmt.Sprintf(tppPolicyResource, "Venafi Inc", ""), "key_size = 2048", "key_size = 2048\n  ecdsa_curve = \"P224\"", 1),
+				ExpectError: regexp.MustCompile("ecdsa_curve must be one of P256, P384 or P521, got P224"),
+			},
+			r.TestStep{
+				//errors other than an attribute which isn't set fail the refresh instead of showing empty values
+				PreConfig: func() {
+					store.Lock()
+					defer store.Unlock()
+					store.readError = "Insufficient rights"
+				},
+				Config:      tppTestProviderConfig(server) + fmt.Sprintf(tppPolicyResource, "Venafi Inc", ""),
+				ExpectError: regexp.MustCompile("Config/ReadPolicy failed for .*: Insufficient rights"),
+			},
+			r.TestStep{
+				PreConfig: func() {
+					store.Lock()
+					defer store.Unlock()
+					store.readError = ""
+				},
+				Config: tppTestProviderConfig(server) + fmt.Sprintf(tppPolicyResource, "Venafi Inc", ""),
+			},
+		},
+	})
+}
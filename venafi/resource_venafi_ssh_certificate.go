@@ -0,0 +1,419 @@
+package venafi
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/certificate"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"github.com/hashicorp/terraform/helper/schema"
+	xed25519 "golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sshTemplateRoot = "\\VED\\Certificate Authority\\SSH\\Templates\\"
+
+var (
+	sshRetrieveTimeout  = 180 * time.Second
+	sshRetrieveInterval = 2 * time.Second
+)
+
+func resourceVenafiSSHCertificate() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceVenafiSSHCertificateCreate,
+		Read:   resourceVenafiSSHCertificateRead,
+		Delete: resourceVenafiSSHCertificateDelete,
+
+		CustomizeDiff: resourceVenafiSSHCertificateCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"template": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name or DN of the SSH certificate issuance template",
+			},
+			"key_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Key identifier of the certificate",
+			},
+			"principals": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "User names or host names the certificate is valid for",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"valid_hours": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Validity period in hours. Template default is used when not set.",
+			},
+			"extensions": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Certificate extensions, e.g. permit-pty",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"source_addresses": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Addresses in CIDR notation the certificate can be used from",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"force_command": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Command which is executed instead of the one requested by the client",
+			},
+			"policy_dn": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Policy folder for the certificate object. Template default is used when not set.",
+			},
+			"object_name": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "Name of the certificate object. Key ID is used when not set.",
+			},
+			"public_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "OpenSSH public key to certify. A key pair is generated when not set.",
+			},
+			"key_algorithm": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      algorithmRSA,
+				Description:  "Algorithm of the generated key, RSA, ECDSA or ED25519",
+				ValidateFunc: validateKeyAlgorithm,
+			},
+			"rsa_bits": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      2048,
+				ValidateFunc: validateRSAKeySize,
+			},
+			"ecdsa_curve": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "P521",
+				ValidateFunc: validateECDSACurve,
+			},
+			"certificate": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Signed certificate in OpenSSH format",
+			},
+			"public_key_openssh": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"private_key_pem": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "Generated private key in a format OpenSSH reads, empty when public_key is set",
+			},
+			"certificate_dn": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"guid": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"serial": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"valid_after": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"valid_before": &schema.Schema{
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceVenafiSSHCertificateCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Get("public_key").(string) != "" {
+		_, _, _, _, err := ssh.ParseAuthorizedKey([]byte(d.Get("public_key").(string)))
+		if err != nil {
+			return fmt.Errorf("error parsing public_key: %s", err)
+		}
+		return nil
+	}
+	if d.Get("key_algorithm").(string) == algorithmECDSA && d.Get("ecdsa_curve").(string) == "P224" {
+		return fmt.Errorf("ECDSA curve P224 is not supported by OpenSSH")
+	}
+	if !keyChanged(d, "key_algorithm", "rsa_bits", "ecdsa_curve") {
+		return nil
+	}
+	return checkKeyStrength(d.Get("key_algorithm").(string), d.Get("rsa_bits").(int), d.Get("ecdsa_curve").(string), false)
+}
+
+type sshCertificateResponse struct {
+	DN                string `json:"DN"`
+	Guid              string `json:"Guid"`
+	CertificateData   string `json:"CertificateData"`
+	ProcessingDetails struct {
+		InError bool   `json:"InError"`
+		Status  string `json:"Status"`
+	} `json:"ProcessingDetails"`
+	Response struct {
+		Success      bool   `json:"Success"`
+		ErrorCode    int    `json:"ErrorCode"`
+		ErrorMessage string `json:"ErrorMessage"`
+	} `json:"Response"`
+}
+
+func resourceVenafiSSHCertificateCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*providerConfig)
+	if config.vcert.ConnectorType != endpoint.ConnectorTypeTPP {
+		diagnostic := newDiagnostic(config, "venafi_ssh_certificate is supported only by Venafi Platform", nil)
+		diagnostic.hint = "configure the provider with url, tpp_username and tpp_password"
+		return diagnostic
+	}
+
+	var (
+		publicKey ssh.PublicKey
+		keyPEM    string
+		err       error
+	)
+	if authorized := d.Get("public_key").(string); authorized != "" {
+		publicKey, _, _, _, err = ssh.ParseAuthorizedKey([]byte(authorized))
+		if err != nil {
+			return fmt.Errorf("error parsing public_key: %s", err)
+		}
+	} else {
+		publicKey, keyPEM, err = generateSSHKey(d.Get("key_algorithm").(string), d.Get("rsa_bits").(int), d.Get("ecdsa_curve").(string))
+		if err != nil {
+			return fmt.Errorf("error generating key: %s", err)
+		}
+	}
+
+	template := d.Get("template").(string)
+	if !strings.HasPrefix(template, "\\VED\\") {
+		template = sshTemplateRoot + template
+	}
+	req := map[string]interface{}{
+		"CADN":          template,
+		"KeyId":         d.Get("key_id").(string),
+		"PublicKeyData": strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+	}
+	if principals := toStringSlice(d.Get("principals").([]interface{})); len(principals) > 0 {
+		req["Principals"] = principals
+	}
+	if hours := d.Get("valid_hours").(int); hours > 0 {
+		req["ValidityPeriod"] = strconv.Itoa(hours) + "h"
+	}
+	if extensions := d.Get("extensions").(map[string]interface{}); len(extensions) > 0 {
+		req["Extensions"] = extensions
+	}
+	if addresses := toStringSlice(d.Get("source_addresses").([]interface{})); len(addresses) > 0 {
+		req["SourceAddresses"] = addresses
+	}
+	for key, attribute := range map[string]string{"force_command": "ForceCommand", "object_name": "ObjectName"} {
+		if v := d.Get(key).(string); v != "" {
+			req[attribute] = v
+		}
+	}
+	if v := d.Get("policy_dn").(string); v != "" {
+		req["PolicyDN"] = tppPolicyDN(v)
+	}
+
+	c, err := newRestClient(config.stopContext, config)
+	if err != nil {
+		return newDiagnostic(config, "error connecting to Venafi", err)
+	}
+	log.Printf("[INFO] Requesting SSH certificate %s from template %s", req["KeyId"], template)
+	var res sshCertificateResponse
+	err = c.request("POST", "SSHCertificates/Request", req, &res)
+	if err == nil && !res.Response.Success {
+		err = errors.New(res.Response.ErrorMessage)
+	}
+	if err != nil {
+		return newDiagnostic(config, "error requesting SSH certificate", err)
+	}
+	d.SetId(res.DN)
+
+	cert, err := retrieveSSHCertificate(c, res.DN)
+	if err != nil {
+		diagnostic := newDiagnostic(config, "error retrieving SSH certificate", err)
+		diagnostic.pickupID = res.DN
+		return diagnostic
+	}
+	if !bytes.Equal(cert.Key.Marshal(), publicKey.Marshal()) {
+		return fmt.Errorf("SSH certificate %s is issued for another public key", res.DN)
+	}
+
+	attributes := map[string]interface{}{
+		"certificate":        strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))),
+		"public_key_openssh": strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		"private_key_pem":    keyPEM,
+		"certificate_dn":     res.DN,
+		"guid":               res.Guid,
+		"serial":             strconv.FormatUint(cert.Serial, 10),
+		"valid_after":        time.Unix(int64(cert.ValidAfter), 0).UTC().Format(time.RFC3339),
+		"valid_before":       sshValidBefore(cert.ValidBefore),
+	}
+	for k, v := range attributes {
+		if err = d.Set(k, v); err != nil {
+			return fmt.Errorf("error setting %s: %s", k, err)
+		}
+	}
+	return nil
+}
+
+// retrieveSSHCertificate waits until Venafi Platform issues the certificate
+func retrieveSSHCertificate(c *restClient, dn string) (*ssh.Certificate, error) {
+	deadline := time.Now().Add(sshRetrieveTimeout)
+	for {
+		var res sshCertificateResponse
+		err := c.request("POST", "SSHCertificates/Retrieve", map[string]interface{}{"DN": dn, "IncludeCertificateDetails": true}, &res)
+		if err != nil {
+			return nil, err
+		}
+		if res.CertificateData != "" {
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(res.CertificateData))
+			if err != nil {
+				return nil, fmt.Errorf("error parsing SSH certificate: %s", err)
+			}
+			cert, ok := key.(*ssh.Certificate)
+			if !ok {
+				return nil, fmt.Errorf("Venafi Platform returned %s instead of SSH certificate", key.Type())
+			}
+			return cert, nil
+		}
+		if res.ProcessingDetails.InError {
+			return nil, fmt.Errorf("error issuing SSH certificate %s: %s %s", dn, res.ProcessingDetails.Status, res.Response.ErrorMessage)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for SSH certificate %s, status: %s", dn, res.ProcessingDetails.Status)
+		}
+		log.Printf("[DEBUG] SSH certificate %s is not issued yet, status: %s", dn, res.ProcessingDetails.Status)
+		if err = sleepContext(c.context(), sshRetrieveInterval); err != nil {
+			return nil, fmt.Errorf("interrupted while waiting for SSH certificate %s, status: %s", dn, res.ProcessingDetails.Status)
+		}
+	}
+}
+
+func sshValidBefore(validBefore uint64) string {
+	if validBefore == ssh.CertTimeInfinity {
+		return "forever"
+	}
+	return time.Unix(int64(validBefore), 0).UTC().Format(time.RFC3339)
+}
+
+func resourceVenafiSSHCertificateRead(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+func resourceVenafiSSHCertificateDelete(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("")
+	return nil
+}
+
+// generateSSHKey returns the public key and the private key PEM which OpenSSH can read
+func generateSSHKey(algorithm string, rsaBits int, curve string) (ssh.PublicKey, string, error) {
+	switch algorithm {
+	case algorithmED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, "", err
+		}
+		//x/crypto/ssh uses its own ed25519 types
+		publicKey, err := ssh.NewPublicKey(xed25519.PublicKey(pub))
+		if err != nil {
+			return nil, "", err
+		}
+		keyPEM, err := marshalOpenSSHED25519PrivateKey(pub, priv)
+		return publicKey, keyPEM, err
+	case algorithmECDSA:
+		//EllipticCurve.Set maps unknown names to the default curve instead of failing
+		if _, errs := validateECDSACurve(curve, "ecdsa_curve"); len(errs) > 0 {
+			return nil, "", errs[0]
+		}
+		var c certificate.EllipticCurve
+		if err := c.Set(curve); err != nil {
+			return nil, "", err
+		}
+		pk, err := certificate.GenerateECDSAPrivateKey(c)
+		if err != nil {
+			return nil, "", err
+		}
+		publicKey, err := ssh.NewPublicKey(&pk.PublicKey)
+		if err != nil {
+			return nil, "", err
+		}
+		keyPEM, err := encodePrivateKey(pk, "", privateKeyFormatPKCS1)
+		return publicKey, keyPEM, err
+	default:
+		pk, err := certificate.GenerateRSAPrivateKey(rsaBits)
+		if err != nil {
+			return nil, "", err
+		}
+		publicKey, err := ssh.NewPublicKey(&pk.PublicKey)
+		if err != nil {
+			return nil, "", err
+		}
+		keyPEM, err := encodePrivateKey(pk, "", privateKeyFormatPKCS1)
+		return publicKey, keyPEM, err
+	}
+}
+
+// marshalOpenSSHED25519PrivateKey encodes the key in unencrypted openssh-key-v1 format, OpenSSH has no PEM form for Ed25519.
+func marshalOpenSSHED25519PrivateKey(pub ed25519.PublicKey, priv ed25519.PrivateKey) (string, error) {
+	appendString := func(b []byte, s []byte) []byte {
+		b = append(b, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(s)))
+		return append(b, s...)
+	}
+	publicKey := appendString(appendString(nil, []byte(ssh.KeyAlgoED25519)), pub)
+
+	check := make([]byte, 4)
+	if _, err := rand.Read(check); err != nil {
+		return "", err
+	}
+	private := append(append([]byte{}, check...), check...)
+	private = appendString(private, []byte(ssh.KeyAlgoED25519))
+	private = appendString(private, pub)
+	private = appendString(private, priv)
+	private = appendString(private, nil)
+	for i := byte(1); len(private)%8 != 0; i++ {
+		private = append(private, i)
+	}
+
+	b := append([]byte("openssh-key-v1"), 0)
+	b = appendString(b, []byte("none"))
+	b = appendString(b, []byte("none"))
+	b = appendString(b, nil)
+	b = append(b, 0, 0, 0, 1)
+	b = appendString(b, publicKey)
+	b = appendString(b, private)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: b})), nil
+}
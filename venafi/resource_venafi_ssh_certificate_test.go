@@ -0,0 +1,170 @@
+package venafi
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	xed25519 "golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sshCAHandlers signs requested public keys with an in-memory SSH CA after one pending retrieve
+func sshCAHandlers(t *testing.T, signer ssh.Signer) map[string]http.HandlerFunc {
+	var (
+		request  map[string]interface{}
+		retrieve int
+	)
+	return map[string]http.HandlerFunc{
+		"/vedsdk/SSHCertificates/Request": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&request)
+			if request["CADN"] != sshTemplateRoot+"users" || request["KeyId"] != "deploy" || request["ValidityPeriod"] != "4h" {
+				t.Errorf("unexpected request %v", request)
+			}
+			fmt.Fprint(w, `{"DN": "\\VED\\Certificates\\SSH\\deploy", "Guid": "{ssh-guid}", "Response": {"Success": true}}`)
+		},
+		"/vedsdk/SSHCertificates/Retrieve": func(w http.ResponseWriter, r *http.Request) {
+			retrieve++
+			if retrieve == 1 {
+				fmt.Fprint(w, `{"ProcessingDetails": {"Status": "Issuing"}, "Response": {"Success": false}}`)
+				return
+			}
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(request["PublicKeyData"].(string)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			principals := []string{}
+			for _, p := range request["Principals"].([]interface{}) {
+				principals = append(principals, p.(string))
+			}
+			cert := &ssh.Certificate{
+				Key:             key,
+				Serial:          7,
+				CertType:        ssh.UserCert,
+				KeyId:           request["KeyId"].(string),
+				ValidPrincipals: principals,
+				ValidAfter:      uint64(time.Now().Unix()),
+				ValidBefore:     uint64(time.Now().Add(4 * time.Hour).Unix()),
+				Permissions: ssh.Permissions{
+					CriticalOptions: map[string]string{"source-address": strings.Join(toStringSlice(request["SourceAddresses"].([]interface{})), ",")},
+					Extensions:      map[string]string{"permit-pty": ""},
+				},
+			}
+			if err = cert.SignCert(rand.Reader, signer); err != nil {
+				t.Error(err)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"CertificateData":   string(ssh.MarshalAuthorizedKey(cert)),
+				"ProcessingDetails": map[string]string{"Status": "Issued"},
+				"Response":          map[string]bool{"Success": true},
+			})
+		},
+	}
+}
+
+const tppSSHCertificateResource = `
+resource "venafi_ssh_certificate" "deploy" {
+  template = "users"
+  key_id = "deploy"
+  principals = ["deploy", "admin"]
+  valid_hours = 4
+  extensions = {
+    permit-pty = ""
+  }
+  source_addresses = ["10.0.0.0/8"]
+  key_algorithm = "ED25519"
+}`
+
+func TestTPPSSHCertificate(t *testing.T) {
+	sshRetrieveInterval = 10 * time.Millisecond
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(xed25519.PrivateKey(caKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTPPTestServer(t, sshCAHandlers(t, signer))
+	defer server.Close()
+
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: tppTestProviderConfig(server) + tppSSHCertificateResource,
+				Check: func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["venafi_ssh_certificate.deploy"].Primary.Attributes
+					key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(attrs["certificate"]))
+					if err != nil {
+						return err
+					}
+					cert := key.(*ssh.Certificate)
+					checker := ssh.CertChecker{IsUserAuthority: func(auth ssh.PublicKey) bool {
+						return string(auth.Marshal()) == string(signer.PublicKey().Marshal())
+					}}
+					if err = checker.CheckCert("admin", cert); err != nil {
+						return err
+					}
+					if attrs["serial"] != "7" || attrs["guid"] != "{ssh-guid}" || cert.Permissions.CriticalOptions["source-address"] != "10.0.0.0/8" {
+						return fmt.Errorf("unexpected certificate attributes %v", attrs)
+					}
+					return checkOpenSSHKeyPair(t, attrs["private_key_pem"], attrs["public_key_openssh"])
+				},
+			},
+		},
+	})
+}
+
+// checkOpenSSHKeyPair verifies with ssh-keygen that OpenSSH reads the generated private key
+func checkOpenSSHKeyPair(t *testing.T, privateKey string, publicKey string) error {
+	keygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Log("ssh-keygen is not available, skipping private key check")
+		return nil
+	}
+	dir, err := ioutil.TempDir("", "venafi")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "id")
+	if err = ioutil.WriteFile(path, []byte(privateKey), 0600); err != nil {
+		return err
+	}
+	out, err := exec.Command(keygen, "-y", "-f", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ssh-keygen can't read private key: %s %s", err, out)
+	}
+	if strings.TrimSpace(string(out)) != publicKey {
+		return fmt.Errorf("public key %s doesn't match private key %s", publicKey, out)
+	}
+	return nil
+}
+
+func TestGenerateSSHKey(t *testing.T) {
+	for _, algorithm := range []string{algorithmRSA, algorithmECDSA, algorithmED25519} {
+		publicKey, keyPEM, err := generateSSHKey(algorithm, 2048, "P256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = checkOpenSSHKeyPair(t, keyPEM, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))); err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+	}
+	if _, _, err := generateSSHKey(algorithmECDSA, 0, "P512"); err == nil {
+		t.Fatal("expected error for unknown ECDSA curve P512")
+	}
+}
@@ -0,0 +1,58 @@
+package venafi
+
+import (
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"github.com/hashicorp/terraform/helper/schema"
+	"golang.org/x/net/idna"
+	"net"
+	"strings"
+)
+
+// normalizeDNSName returns the form of a DNS name which is requested: lower case without a trailing dot and with
+// unicode labels converted to punycode. Names which can't be converted are only lower cased.
+func normalizeDNSName(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if ascii, err := idna.ToASCII(name); err == nil {
+		return ascii
+	}
+	return name
+}
+
+// normalizeEmail normalizes the domain of an email address like a DNS name, the local part is case sensitive
+func normalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return email[:at+1] + normalizeDNSName(email[at+1:])
+}
+
+// normalizeIP returns the canonical form of an IP address, like the compressed form of IPv6 addresses. Invalid
+// addresses are returned unchanged and refused when the certificate is requested.
+func normalizeIP(ip string) string {
+	if parsed := net.ParseIP(strings.TrimSpace(ip)); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
+
+// sanSetFunc returns the hash of set elements which are equal after normalization, so reordering SANs or changing
+// their case doesn't show up as a difference
+func sanSetFunc(normalize func(string) string) schema.SchemaSetFunc {
+	return func(v interface{}) int {
+		return hashcode.String(normalize(v.(string)))
+	}
+}
+
+// sanValues returns the normalized elements of a SAN set without duplicates
+func sanValues(set *schema.Set, normalize func(string) string) []string {
+	values := make([]string, 0, set.Len())
+	for _, v := range set.List() {
+		value := normalize(v.(string))
+		if !sliceContains(values, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
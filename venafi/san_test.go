@@ -0,0 +1,70 @@
+package venafi
+
+import (
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"testing"
+)
+
+func TestSANNormalization(t *testing.T) {
+	cases := []struct {
+		normalize func(string) string
+		value     string
+		expected  string
+	}{
+		{normalizeDNSName, "WWW.Venafi.Example.", "www.venafi.example"},
+		{normalizeDNSName, "*.Venafi.Example", "*.venafi.example"},
+		{normalizeDNSName, "Bücher.venafi.example", "xn--bcher-kva.venafi.example"},
+		{normalizeEmail, "Admin@Bücher.EXAMPLE", "Admin@xn--bcher-kva.example"},
+		{normalizeEmail, "admin", "admin"},
+		{normalizeIP, "2001:DB8:0:0:0:0:0:1", "2001:db8::1"},
+		{normalizeIP, "::ffff:192.168.1.1", "192.168.1.1"},
+		{normalizeIP, "192.168.1.256", "192.168.1.256"},
+	}
+	for _, c := range cases {
+		if got := c.normalize(c.value); got != c.expected {
+			t.Errorf("expected %s to be normalized to %s, got %s", c.value, c.expected, got)
+		}
+	}
+}
+
+const devSANConfig = `
+provider "venafi" {
+  dev_mode = true
+}
+resource "venafi_certificate" "dev" {
+  common_name = "Web.venafi.example"
+  san_dns = [%s]
+  san_ip = [%s]
+}`
+
+func TestDevCertificateSANSet(t *testing.T) {
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: fmt.Sprintf(devSANConfig, `"web.venafi.example", "api.venafi.example", "WEB.venafi.example."`, `"2001:db8::1", "192.168.1.1"`),
+				Check: func(s *terraform.State) error {
+					certs, err := parseCertificates(s.RootModule().Resources["venafi_certificate.dev"].Primary.Attributes["certificate"])
+					if err != nil {
+						return err
+					}
+					dns := certs[0].DNSNames
+					if len(dns) != 2 || !sameStringSlice(dns, []string{"web.venafi.example", "api.venafi.example"}) {
+						return fmt.Errorf("expected the CN and api.venafi.example once in DNS names, got %s", dns)
+					}
+					if len(certs[0].IPAddresses) != 2 {
+						return fmt.Errorf("expected 2 IP addresses, got %s", certs[0].IPAddresses)
+					}
+					return nil
+				},
+			},
+			r.TestStep{
+				//reordered, differently written and duplicated SANs don't request a new certificate
+				Config:   fmt.Sprintf(devSANConfig, `"API.venafi.example", "web.venafi.example", "api.venafi.example"`, `"192.168.1.1", "2001:DB8:0:0::1"`),
+				PlanOnly: true,
+			},
+		},
+	})
+}
@@ -0,0 +1,198 @@
+package venafi
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform/helper/hashcode"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// certificateSearch is the filter of the venafi_certificates data source, empty values are not applied.
+type certificateSearch struct {
+	zone           string
+	commonName     string
+	san            string
+	issuer         string
+	keySize        int
+	expiringWithin time.Duration
+	limit          int
+	offset         int
+}
+
+// id identifies the search by its filter, so the data source ID only changes with its arguments
+func (s certificateSearch) id() string {
+	filter := strings.Join([]string{
+		s.zone, s.commonName, s.san, s.issuer, strconv.Itoa(s.keySize), s.expiringWithin.String(), strconv.Itoa(s.limit), strconv.Itoa(s.offset),
+	}, "\x00")
+	return strconv.Itoa(hashcode.String(filter))
+}
+
+// foundCertificate is the metadata returned by certificate search of both backends
+type foundCertificate struct {
+	id              string
+	certificateDN   string
+	commonName      string
+	sanDNS          []string
+	issuer          string
+	keySize         int
+	serialNumber    string
+	sha1Fingerprint string
+	notBefore       string
+	notAfter        string
+}
+
+type tppSearchResponse struct {
+	Certificates []struct {
+		DN   string `json:"DN"`
+		Guid string `json:"Guid"`
+		X509 struct {
+			CN         string `json:"CN"`
+			Issuer     string `json:"Issuer"`
+			KeySize    int    `json:"KeySize"`
+			Serial     string `json:"Serial"`
+			Thumbprint string `json:"Thumbprint"`
+			ValidFrom  string `json:"ValidFrom"`
+			ValidTo    string `json:"ValidTo"`
+			SANS       struct {
+				DNS []string `json:"DNS"`
+			} `json:"SANS"`
+		} `json:"X509"`
+	} `json:"Certificates"`
+	TotalCount int `json:"TotalCount"`
+}
+
+// searchTPPCertificates uses GET certificates/ of WebSDK. Zone is searched recursively.
+func searchTPPCertificates(c *restClient, s certificateSearch) ([]foundCertificate, int, error) {
+	query := url.Values{}
+	query.Set("Limit", strconv.Itoa(s.limit))
+	query.Set("Offset", strconv.Itoa(s.offset))
+	if s.zone != "" {
+		query.Set("ParentDnRecursive", tppPolicyDN(s.zone))
+	}
+	if s.commonName != "" {
+		query.Set("CN", s.commonName)
+	}
+	if s.san != "" {
+		query.Set("SAN-DNS", s.san)
+	}
+	if s.issuer != "" {
+		query.Set("Issuer", s.issuer)
+	}
+	if s.keySize != 0 {
+		query.Set("KeySize", strconv.Itoa(s.keySize))
+	}
+	if s.expiringWithin != 0 {
+		query.Set("ValidToLess", time.Now().Add(s.expiringWithin).UTC().Format(time.RFC3339))
+	}
+
+	var res tppSearchResponse
+	err := c.request("GET", "certificates/?"+query.Encode(), nil, &res)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search certificates: %s", err)
+	}
+	found := make([]foundCertificate, 0, len(res.Certificates))
+	for _, cert := range res.Certificates {
+		found = append(found, foundCertificate{
+			id:              cert.Guid,
+			certificateDN:   cert.DN,
+			commonName:      cert.X509.CN,
+			sanDNS:          cert.X509.SANS.DNS,
+			issuer:          cert.X509.Issuer,
+			keySize:         cert.X509.KeySize,
+			serialNumber:    cert.X509.Serial,
+			sha1Fingerprint: strings.ToUpper(cert.X509.Thumbprint),
+			notBefore:       cert.X509.ValidFrom,
+			notAfter:        cert.X509.ValidTo,
+		})
+	}
+	return found, res.TotalCount, nil
+}
+
+type cloudSearchOperand struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+type cloudSearchRequest struct {
+	Expression struct {
+		Operands []cloudSearchOperand `json:"operands,omitempty"`
+	} `json:"expression"`
+	Paging struct {
+		PageNumber int `json:"pageNumber"`
+		PageSize   int `json:"pageSize"`
+	} `json:"paging"`
+}
+
+type cloudSearchResponse struct {
+	Count        int `json:"count"`
+	Certificates []struct {
+		ID                        string   `json:"id"`
+		SubjectCN                 []string `json:"subjectCN"`
+		SubjectAlternativeNameDNS []string `json:"subjectAlternativeNameDns"`
+		IssuerCN                  []string `json:"issuerCN"`
+		KeyStrength               int      `json:"keyStrength"`
+		SerialNumber              string   `json:"serialNumber"`
+		Fingerprint               string   `json:"fingerprint"`
+		ValidityStart             string   `json:"validityStart"`
+		ValidityEnd               string   `json:"validityEnd"`
+	} `json:"certificates"`
+}
+
+// searchCloudCertificates uses POST certificatesearch of Venafi Cloud. Cloud pages by number,
+// so offset is rounded down to a multiple of limit.
+func searchCloudCertificates(c *restClient, s certificateSearch) ([]foundCertificate, int, error) {
+	req := cloudSearchRequest{}
+	req.Paging.PageSize = s.limit
+	req.Paging.PageNumber = s.offset / s.limit
+	operands := []cloudSearchOperand{}
+	if s.zone != "" {
+		var zone struct {
+			ID string `json:"id"`
+		}
+		err := c.request("GET", "zones/tag/"+url.PathEscape(s.zone), nil, &zone)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read zone %s: %s", s.zone, err)
+		}
+		operands = append(operands, cloudSearchOperand{"zoneId", "EQ", zone.ID})
+	}
+	if s.commonName != "" {
+		operands = append(operands, cloudSearchOperand{"subjectCN", "FIND", s.commonName})
+	}
+	if s.san != "" {
+		operands = append(operands, cloudSearchOperand{"subjectAlternativeNameDns", "FIND", s.san})
+	}
+	if s.issuer != "" {
+		operands = append(operands, cloudSearchOperand{"issuerCN", "FIND", s.issuer})
+	}
+	if s.keySize != 0 {
+		operands = append(operands, cloudSearchOperand{"keyStrength", "EQ", s.keySize})
+	}
+	if s.expiringWithin != 0 {
+		operands = append(operands, cloudSearchOperand{"validityEnd", "LTE", time.Now().Add(s.expiringWithin).UTC().Format(time.RFC3339)})
+	}
+	req.Expression.Operands = operands
+
+	var res cloudSearchResponse
+	err := c.request("POST", "certificatesearch", req, &res)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search certificates: %s", err)
+	}
+	found := make([]foundCertificate, 0, len(res.Certificates))
+	for _, cert := range res.Certificates {
+		found = append(found, foundCertificate{
+			id:              cert.ID,
+			commonName:      strings.Join(cert.SubjectCN, ","),
+			sanDNS:          cert.SubjectAlternativeNameDNS,
+			issuer:          strings.Join(cert.IssuerCN, ","),
+			keySize:         cert.KeyStrength,
+			serialNumber:    cert.SerialNumber,
+			sha1Fingerprint: strings.ToUpper(cert.Fingerprint),
+			notBefore:       cert.ValidityStart,
+			notAfter:        cert.ValidityEnd,
+		})
+	}
+	return found, res.Count, nil
+}
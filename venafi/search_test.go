@@ -0,0 +1,212 @@
+package venafi
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	r "github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const tppSearchResult = `{
+  "Certificates": [{
+    "DN": "\\VED\\Policy\\devops\\web.venafi.example",
+    "Guid": "{4f7a4b3c-1111-2222-3333-444455556666}",
+    "X509": {
+      "CN": "web.venafi.example",
+      "Issuer": "CN=Venafi Issuing CA",
+      "KeySize": 2048,
+      "Serial": "1A2B3C",
+      "Thumbprint": "5a19f41c0d4c9e7b8a1f2e3d4c5b6a7980706050",
+      "ValidFrom": "2019-01-01T00:00:00.0000000Z",
+      "ValidTo": "2019-04-01T00:00:00.0000000Z",
+      "SANS": {"DNS": ["web.venafi.example", "www.venafi.example"]}
+    }
+  }],
+  "TotalCount": 3
+}`
+
+// newTPPTestServer starts a TLS server answering WebSDK ping and authorize calls in addition to the given handlers
+func newTPPTestServer(t *testing.T, handlers map[string]http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vedsdk/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vedsdk/" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/vedsdk/authorize/", func(w http.ResponseWriter, r *http.Request) {
+		var auth map[string]string
+		json.NewDecoder(r.Body).Decode(&auth)
+		if auth["Username"] != "admin" || auth["Password"] != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"APIKey": "test-api-key", "ValidUntil": "/Date(1552560000000)/"}`)
+	})
+	for path, handler := range handlers {
+		h := handler
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Venafi-Api-Key") != "test-api-key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			h(w, r)
+		})
+	}
+	return httptest.NewTLSServer(mux)
+}
+
+// tppTestProviderConfig returns a provider block trusting the test server
+func tppTestProviderConfig(server *httptest.Server) string {
+	trust := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return fmt.Sprintf(`
+provider "venafi" {
+  url = "%s/vedsdk"
+  zone = "devops"
+  tpp_username = "admin"
+  tpp_password = "secret"
+  trust_bundle = <<EOF
+%sEOF
+}`, server.URL, trust)
+}
+
+func TestTPPSearchCertificates(t *testing.T) {
+	server := newTPPTestServer(t, map[string]http.HandlerFunc{
+		"/vedsdk/certificates/": func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			expected := map[string]string{
+				"ParentDnRecursive": "\\VED\\Policy\\devops",
+				"CN":                "web.venafi.example",
+				"KeySize":           "2048",
+				"Limit":             "10",
+				"Offset":            "20",
+			}
+			for k, v := range expected {
+				if q.Get(k) != v {
+					t.Errorf("expected query %s=%s, got %s", k, v, q.Get(k))
+				}
+			}
+			validTo, err := time.Parse(time.RFC3339, q.Get("ValidToLess"))
+			if err != nil || validTo.Before(time.Now().Add(719*time.Hour)) {
+				t.Errorf("unexpected ValidToLess %s", q.Get("ValidToLess"))
+			}
+			fmt.Fprint(w, tppSearchResult)
+		},
+	})
+	defer server.Close()
+
+	config := tppTestProviderConfig(server) + `
+data "venafi_certificates" "expiring" {
+  zone = "devops"
+  common_name = "web.venafi.example"
+  key_size = 2048
+  expiring_within = 720
+  limit = 10
+  offset = 20
+}`
+	r.Test(t, r.TestCase{
+		Providers: testProviders,
+		Steps: []r.TestStep{
+			r.TestStep{
+				Config: config,
+				Check: func(s *terraform.State) error {
+					attrs := s.RootModule().Resources["data.venafi_certificates.expiring"].Primary.Attributes
+					expected := map[string]string{
+						"total_count":                     "3",
+						"certificates.#":                  "1",
+						"certificates.0.certificate_dn":   "\\VED\\Policy\\devops\\web.venafi.example",
+						"certificates.0.san_dns.#":        "2",
+						"certificates.0.sha1_fingerprint": "5A19F41C0D4C9E7B8A1F2E3D4C5B6A7980706050",
+						"certificates.0.key_size":         "2048",
+					}
+					for k, v := range expected {
+						if attrs[k] != v {
+							return fmt.Errorf("expected %s to be %s, got %s", k, v, attrs[k])
+						}
+					}
+					search := certificateSearch{zone: "devops", commonName: "web.venafi.example", keySize: 2048, expiringWithin: 720 * time.Hour, limit: 10, offset: 20}
+					if attrs["id"] != search.id() {
+						return fmt.Errorf("expected id %s of the filter, got %s", search.id(), attrs["id"])
+					}
+					return nil
+				},
+			},
+			r.TestStep{
+				Config:      strings.Replace(config, "limit = 10", "limit = 1001", 1),
+				ExpectError: regexp.MustCompile("limit must be between 1 and 1000, got 1001"),
+			},
+			r.TestStep{
+				Config:      strings.Replace(config, "offset = 20", "offset = -1", 1),
+				ExpectError: regexp.MustCompile("offset can't be negative, got -1"),
+			},
+		},
+	})
+}
+
+func TestCertificateSearchID(t *testing.T) {
+	search := certificateSearch{zone: "devops", commonName: "web.venafi.example", limit: 100}
+	if search.id() != search.id() {
+		t.Fatal("id of the same filter changed")
+	}
+	next := search
+	next.offset = 100
+	if next.id() == search.id() {
+		t.Fatalf("filters with different offsets share id %s", search.id())
+	}
+}
+
+func TestCloudSearchCertificates(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/zones/tag/Default", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "zone-id"}`)
+	})
+	mux.HandleFunc("/v1/certificatesearch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("tppl-api-key") != "test-api-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req cloudSearchRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Paging.PageNumber != 2 || req.Paging.PageSize != 10 {
+			t.Errorf("unexpected paging %+v", req.Paging)
+		}
+		if len(req.Expression.Operands) != 2 || req.Expression.Operands[0].Value != "zone-id" || req.Expression.Operands[1].Field != "subjectAlternativeNameDns" {
+			t.Errorf("unexpected operands %+v", req.Expression.Operands)
+		}
+		fmt.Fprint(w, `{"count": 21, "certificates": [{"id": "cert-id", "subjectCN": ["web.venafi.example"], "issuerCN": ["Venafi CA"], "keyStrength": 2048, "fingerprint": "ab01"}]}`)
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	c := &restClient{baseURL: normalizeCloudURL(server.URL), authHeader: "tppl-api-key", apiKey: "test-api-key", http: server.Client()}
+	found, total, err := searchCloudCertificates(c, certificateSearch{zone: "Default", san: "venafi.example", limit: 10, offset: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 21 || len(found) != 1 || found[0].id != "cert-id" || found[0].sha1Fingerprint != "AB01" || found[0].issuer != "Venafi CA" {
+		t.Fatalf("unexpected search result %d %+v", total, found)
+	}
+}
+
+func TestNormalizeURLs(t *testing.T) {
+	cases := map[string]string{
+		normalizeTPPURL("tpp.venafi.example"):                "https://tpp.venafi.example/vedsdk/",
+		normalizeTPPURL("https://tpp.venafi.example/vedsdk"): "https://tpp.venafi.example/vedsdk/",
+		normalizeCloudURL("https://api.venafi.cloud/v1/"):    "https://api.venafi.cloud/v1/",
+		normalizeCloudURL("api.dev.venafi.cloud"):            "https://api.dev.venafi.cloud/v1/",
+		tppPolicyDN("devops\\web"):                           "\\VED\\Policy\\devops\\web",
+		tppPolicyDN("\\VED\\Policy\\devops"):                 "\\VED\\Policy\\devops",
+	}
+	for got, expected := range cases {
+		if got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+}
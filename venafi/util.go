@@ -19,6 +19,58 @@ func sliceContains(slice []string, item string) bool {
 	return ok
 }
 
+var keyUsages = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digital_signature"},
+	{x509.KeyUsageContentCommitment, "content_commitment"},
+	{x509.KeyUsageKeyEncipherment, "key_encipherment"},
+	{x509.KeyUsageDataEncipherment, "data_encipherment"},
+	{x509.KeyUsageKeyAgreement, "key_agreement"},
+	{x509.KeyUsageCertSign, "cert_signing"},
+	{x509.KeyUsageCRLSign, "crl_signing"},
+	{x509.KeyUsageEncipherOnly, "encipher_only"},
+	{x509.KeyUsageDecipherOnly, "decipher_only"},
+}
+
+var extKeyUsages = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                        "any_extended",
+	x509.ExtKeyUsageServerAuth:                 "server_auth",
+	x509.ExtKeyUsageClientAuth:                 "client_auth",
+	x509.ExtKeyUsageCodeSigning:                "code_signing",
+	x509.ExtKeyUsageEmailProtection:            "email_protection",
+	x509.ExtKeyUsageIPSECEndSystem:             "ipsec_end_system",
+	x509.ExtKeyUsageIPSECTunnel:                "ipsec_tunnel",
+	x509.ExtKeyUsageIPSECUser:                  "ipsec_user",
+	x509.ExtKeyUsageTimeStamping:               "timestamping",
+	x509.ExtKeyUsageOCSPSigning:                "ocsp_signing",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto: "microsoft_server_gated_crypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:  "netscape_server_gated_crypto",
+}
+
+func keyUsageNames(ku x509.KeyUsage) []string {
+	names := []string{}
+	for _, u := range keyUsages {
+		if ku&u.usage != 0 {
+			names = append(names, u.name)
+		}
+	}
+	return names
+}
+
+func extKeyUsageNames(eku []x509.ExtKeyUsage) []string {
+	names := make([]string, 0, len(eku))
+	for _, u := range eku {
+		if name, ok := extKeyUsages[u]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, fmt.Sprintf("unknown_%d", u))
+		}
+	}
+	return names
+}
+
 func randSeq(n int) string {
 	rand.Seed(time.Now().UTC().UnixNano())
 	var letters = []rune("abcdefghijklmnopqrstuvwxyz1234567890")
@@ -77,7 +129,13 @@ func getPrivateKey(keyBytes []byte, passphrase string) ([]byte, error) {
 	}
 
 	var err error
-	if x509.IsEncryptedPEMBlock(pemBlock) {
+	if pemBlock.Type == "ENCRYPTED PRIVATE KEY" {
+		keyBytes, err = decryptPKCS8PrivateKey(pemBlock.Bytes, []byte(passphrase))
+		if err != nil {
+			return nil, errors.Wrap(err, "private key is encrypted, but could not decrypt it")
+		}
+		keyBytes = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})
+	} else if x509.IsEncryptedPEMBlock(pemBlock) {
 		keyBytes, err = x509.DecryptPEMBlock(pemBlock, []byte(passphrase))
 		if err != nil {
 			return nil, errors.Wrap(err, "private key is encrypted, but could not decrypt it")
@@ -0,0 +1,163 @@
+package venafi
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/Venafi/vcert/pkg/endpoint"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	cloudDefaultURL = "https://api.venafi.cloud/v1/"
+
+	tppPolicyRoot = "\\VED\\Policy"
+)
+
+// restClient calls Venafi Platform WebSDK and Venafi Cloud endpoints which vcert connectors don't expose.
+type restClient struct {
+	baseURL    string
+	authHeader string
+	apiKey     string
+	http       *http.Client
+	//ctx cancels requests in flight when Terraform is interrupted, nil never cancels
+	ctx context.Context
+}
+
+// newRestClient returns a client authenticated the same way as the vcert connector built from config.
+// The API token of Venafi Platform is redacted from the logs with the secrets of config.
+func newRestClient(ctx context.Context, config *providerConfig) (*restClient, error) {
+	cfg := config.vcert
+	httpClient := http.DefaultClient
+	if cfg.ConnectionTrust != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(cfg.ConnectionTrust)) {
+			return nil, fmt.Errorf("failed to parse PEM trust bundle")
+		}
+		httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	}
+	if cfg.Credentials == nil {
+		return nil, fmt.Errorf("missing credentials")
+	}
+
+	switch cfg.ConnectorType {
+	case endpoint.ConnectorTypeTPP:
+		c := &restClient{baseURL: normalizeTPPURL(cfg.BaseUrl), authHeader: "X-Venafi-Api-Key", http: httpClient, ctx: ctx}
+		var auth struct {
+			APIKey string `json:"APIKey"`
+		}
+		err := c.request("POST", "authorize/", map[string]string{"Username": cfg.Credentials.User, "Password": cfg.Credentials.Password}, &auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authorize to Venafi Platform: %s", err)
+		}
+		c.apiKey = auth.APIKey
+		config.secrets.add(c.apiKey)
+		return c, nil
+	case endpoint.ConnectorTypeCloud:
+		baseURL := cloudDefaultURL
+		if cfg.BaseUrl != "" {
+			baseURL = normalizeCloudURL(cfg.BaseUrl)
+		}
+		return &restClient{baseURL: baseURL, authHeader: "tppl-api-key", apiKey: cfg.Credentials.APIKey, http: httpClient, ctx: ctx}, nil
+	default:
+		return nil, fmt.Errorf("operation is not supported in dev mode")
+	}
+}
+
+// normalizeTPPURL makes https://tpp.example/vedsdk/ out of the url given to the provider
+func normalizeTPPURL(url string) string {
+	if !strings.HasPrefix(strings.ToLower(url), "https://") && !strings.HasPrefix(strings.ToLower(url), "http://") {
+		url = "https://" + url
+	}
+	url = strings.TrimSuffix(url, "/")
+	if !strings.HasSuffix(strings.ToLower(url), "/vedsdk") {
+		url += "/vedsdk"
+	}
+	return url + "/"
+}
+
+// normalizeCloudURL makes https://api.venafi.cloud/v1/ out of the url given to the provider
+func normalizeCloudURL(url string) string {
+	if !strings.HasPrefix(strings.ToLower(url), "https://") && !strings.HasPrefix(strings.ToLower(url), "http://") {
+		url = "https://" + url
+	}
+	url = strings.TrimSuffix(url, "/")
+	if !strings.HasSuffix(url, "/v1") {
+		url += "/v1"
+	}
+	return url + "/"
+}
+
+// tppPolicyDN prepends \VED\Policy to zones which are given relative to the policy root
+func tppPolicyDN(zone string) string {
+	if strings.HasPrefix(zone, tppPolicyRoot) {
+		return zone
+	}
+	if !strings.HasPrefix(zone, "\\") {
+		zone = "\\" + zone
+	}
+	return tppPolicyRoot + zone
+}
+
+// context returns the context of the client, which is never done when none was given
+func (c *restClient) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// request sends data as JSON and decodes the JSON response into result when it's not nil.
+func (c *restClient) request(method string, resource string, data interface{}, result interface{}) error {
+	var payload []byte
+	if data != nil {
+		var err error
+		payload, err = json.Marshal(data)
+		if err != nil {
+			return err
+		}
+	}
+	r, err := http.NewRequest(method, c.baseURL+resource, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	r = r.WithContext(c.context())
+	//bodies are only logged at trace level, the log filter redacts passwords and keys in them
+	log.Printf("[TRACE] Sending %s %s: %s", method, resource, payload)
+	if c.apiKey != "" {
+		r.Header.Add(c.authHeader, c.apiKey)
+	}
+	r.Header.Add("Accept", "application/json")
+	if data != nil {
+		r.Header.Add("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] Got %s status for %s %s", res.Status, method, resource)
+	log.Printf("[TRACE] Response of %s %s: %s", method, resource, body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s for %s %s: %s", res.Status, method, resource, strings.TrimSpace(string(body)))
+	}
+	if result == nil || len(body) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		return fmt.Errorf("failed to parse response of %s %s: %s", method, resource, err)
+	}
+	return nil
+}